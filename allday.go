@@ -0,0 +1,95 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Date is a day without time and zone, used by all-day events
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// the date of t in its own zone
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{year, month, day}
+}
+
+// parse date in 2006-01-02 format
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return Date{}, errors.Wrapf(err, "invalid date %q", value)
+	}
+	return DateOf(t), nil
+}
+
+func (c Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", c.Year, c.Month, c.Day)
+}
+
+func (c Date) AddDays(days int) Date {
+	return DateOf(c.wallClock().AddDate(0, 0, days))
+}
+
+func (c Date) Before(date Date) bool {
+	return c.wallClock().Before(date.wallClock())
+}
+
+// start of the day in loc, it is not always 00:00 when DST starts at midnight
+func (c Date) In(loc *time.Location) time.Time {
+	return WallClockIn(c.wallClock(), loc)
+}
+
+// midnight of the date as if in UTC, same as how floating events are kept
+func (c Date) wallClock() time.Time {
+	return time.Date(c.Year, c.Month, c.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// all-day event from first to last day, both included
+// Start and End are kept as wall clock like floating events, use Instants to get the real
+// instants for a zone, End is the last second of last day
+func NewAllDayEvent(id int, first, last Date) Event {
+	return Event{
+		CalendarEvent: CalendarEvent{id, first.wallClock().Unix(), last.AddDays(1).wallClock().Unix() - 1},
+		AllDay:        true,
+	}
+}
+
+// first and last day of an all-day event, both included
+func (c *Event) Dates() (first, last Date) {
+	first = DateOf(time.Unix(c.Start, 0).UTC())
+	last = DateOf(time.Unix(c.End, 0).UTC())
+	return
+}
+
+// whether all-day events take part in conflict detection
+type AllDayPolicy int
+
+const (
+	AllDayIgnore   AllDayPolicy = iota // default, holidays do not conflict with meetings
+	AllDayConflict                     // all-day events conflict like any other event
+)
+
+// same as Events.Instants but all-day events are dropped unless the policy is AllDayConflict
+func (c Events) Conflicting(loc *time.Location, policy AllDayPolicy) CalendarEvents {
+	ret := make(CalendarEvents, 0, len(c))
+	for i := range c {
+		if c[i].AllDay && policy != AllDayConflict {
+			continue
+		}
+		ret = append(ret, c[i].Instants(loc))
+	}
+	return ret
+}
+
+// find overlaps of events as seen by a user in loc, floating and all-day events are
+// resolved in loc and all-day events are handled by opts.AllDay
+func FindEventOverlaps(evts Events, loc *time.Location, opts Options) ([]CalendarPair, error) {
+	return FindOverlaps(evts.Conflicting(loc, opts.AllDay), opts)
+}
@@ -0,0 +1,79 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	date, err := ParseDate("2026-10-18")
+	if err != nil || date != (Date{2026, time.October, 18}) {
+		t.Logf("parse date failed: %v, %s", date, err)
+		t.FailNow()
+	}
+	if _, err := ParseDate("2026-13-01"); err == nil {
+		t.Log("invalid date should fail")
+		t.FailNow()
+	}
+	if next := date.AddDays(14); next.String() != "2026-11-01" || !date.Before(next) {
+		t.Logf("2 weeks later should be 2026-11-01 instead of %s", next)
+		t.FailNow()
+	}
+}
+
+func TestAllDayEvent_Instants(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	tokyo := loadLocation(t, "Asia/Tokyo")
+
+	evt := NewAllDayEvent(0, Date{2026, time.November, 1}, Date{2026, time.November, 1})
+	if first, last := evt.Dates(); first != last || first.String() != "2026-11-01" {
+		t.Logf("dates should be 2026-11-01 instead of %s -> %s", first, last)
+		t.FailNow()
+	}
+	// DST ends on this day in New York, so it has 25 hours
+	instants := evt.Instants(newYork)
+	if instants.StartTime(newYork).Format(time.RFC3339) != "2026-11-01T00:00:00-04:00" ||
+		instants.End-instants.Start != 25*3600-1 {
+		t.Logf("all-day event in New York is wrong: %s", instants.ToString())
+		t.FailNow()
+	}
+	instants = evt.Instants(tokyo)
+	if instants.StartTime(tokyo).Format(time.RFC3339) != "2026-11-01T00:00:00+09:00" ||
+		instants.End-instants.Start != 24*3600-1 {
+		t.Logf("all-day event in Tokyo is wrong: %s", instants.ToString())
+		t.FailNow()
+	}
+
+	multi := NewAllDayEvent(1, Date{2026, time.October, 18}, Date{2026, time.October, 20})
+	multi.Title = "Conference"
+	if ret := multi.ToString(); ret != "Conference (#1) 2026-10-18 -> 2026-10-20 all day" {
+		t.Logf("summary is wrong: %s", ret)
+		t.FailNow()
+	}
+}
+
+func TestFindEventOverlaps_AllDay(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	holiday := NewAllDayEvent(0, Date{2026, time.October, 19}, Date{2026, time.October, 19})
+	meeting := NewZonedEvent(1, time.Date(2026, 10, 19, 9, 0, 0, 0, newYork), time.Date(2026, 10, 19, 10, 0, 0, 0, newYork))
+	// starts right after the holiday ends in New York
+	lateNight := NewZonedEvent(2, time.Date(2026, 10, 20, 0, 0, 0, 0, newYork), time.Date(2026, 10, 20, 1, 0, 0, 0, newYork))
+	events := Events{holiday, meeting, lateNight}
+
+	ret, err := FindEventOverlaps(events, newYork, Options{})
+	if err != nil || len(ret) != 0 {
+		t.Logf("all-day events should be ignored by default instead of %v, %v", ret, err)
+		t.FailNow()
+	}
+	ret, err = FindEventOverlaps(events, newYork, Options{AllDay: AllDayConflict})
+	if err != nil || len(ret) != 1 || ret[0] != (CalendarPair{0, 1}) {
+		t.Logf("holiday should conflict with the meeting only instead of %v, %v", ret, err)
+		t.FailNow()
+	}
+	// in UTC the holiday ends at 20:00 in New York, so the late night event does not conflict either
+	ret, err = FindEventOverlaps(events, time.UTC, Options{AllDay: AllDayConflict})
+	if err != nil || len(ret) != 1 || ret[0] != (CalendarPair{0, 1}) {
+		t.Logf("holiday in UTC should conflict with the meeting only instead of %v, %v", ret, err)
+		t.FailNow()
+	}
+}
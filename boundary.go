@@ -0,0 +1,73 @@
+package calendar
+
+// how the Start and End of an event are treated when checking overlaps
+// timestamps are integers, so every mode can be turned into a closed interval:
+// [start, end) is [start, end - 1] and (start, end) is [start + 1, end - 1]
+// all the overlap algorithms and Segments work on the closed version
+type BoundaryMode int
+
+const (
+	BoundaryClosed   BoundaryMode = iota // [start, end], default, back to back events overlap
+	BoundaryHalfOpen                     // [start, end), back to back events do not overlap
+	BoundaryOpen                         // (start, end)
+)
+
+func (c BoundaryMode) String() string {
+	switch c {
+	case BoundaryClosed:
+		return "closed"
+	case BoundaryHalfOpen:
+		return "half-open"
+	case BoundaryOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// return the closed interval covering the same instants as evt in this mode
+// false means the event is empty, e.g. [10, 10), which never overlaps anything
+func (c BoundaryMode) Closed(evt CalendarEvent) (CalendarEvent, bool) {
+	switch c {
+	case BoundaryHalfOpen:
+		evt.End--
+	case BoundaryOpen:
+		evt.Start++
+		evt.End--
+	}
+	return evt, evt.Start <= evt.End
+}
+
+// convert the events into closed intervals, empty events are dropped
+func (c BoundaryMode) Normalize(evts CalendarEvents) CalendarEvents {
+	ret := make(CalendarEvents, 0, len(evts))
+	for _, evt := range evts {
+		if closed, ok := c.Closed(evt); ok {
+			ret = append(ret, closed)
+		}
+	}
+	return ret
+}
+
+// check overlap in given mode, same as isOverlap for BoundaryClosed
+func (c *CalendarEvent) Overlaps(evt CalendarEvent, mode BoundaryMode) bool {
+	left, ok := mode.Closed(*c)
+	if !ok {
+		return false
+	}
+	right, ok := mode.Closed(evt)
+	if !ok {
+		return false
+	}
+	return left.isOverlap(right)
+}
+
+// segments are always closed, return the bounds of the segment in given mode
+func (c *Segment) Bounds(mode BoundaryMode) (start, end int64) {
+	switch mode {
+	case BoundaryHalfOpen:
+		return c.Start, c.End + 1
+	case BoundaryOpen:
+		return c.Start - 1, c.End + 1
+	}
+	return c.Start, c.End
+}
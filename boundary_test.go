@@ -0,0 +1,146 @@
+package calendar
+
+import (
+	"sort"
+	"testing"
+)
+
+var boundaryModes = []BoundaryMode{BoundaryClosed, BoundaryHalfOpen, BoundaryOpen}
+
+// pairs by checking every two events with CalendarEvent.Overlaps
+func brutalPairsMode(evts CalendarEvents, mode BoundaryMode) (ret []CalendarPair) {
+	for i := 0; i < len(evts); i++ {
+		for p := i + 1; p < len(evts); p++ {
+			if evts[i].Overlaps(evts[p], mode) {
+				ret = append(ret, newCalendarPair(evts[i].Id, evts[p].Id))
+			}
+		}
+	}
+	return
+}
+
+func TestCalendarEvent_Overlaps(t *testing.T) {
+	cases := []struct {
+		left, right CalendarEvent
+		expected    []bool // closed, half open, open
+	}{
+		{CalendarEvent{0, 100, 200}, CalendarEvent{1, 200, 300}, []bool{true, false, false}},
+		{CalendarEvent{0, 100, 200}, CalendarEvent{1, 199, 300}, []bool{true, true, false}},
+		{CalendarEvent{0, 100, 200}, CalendarEvent{1, 198, 300}, []bool{true, true, true}},
+		{CalendarEvent{0, 100, 200}, CalendarEvent{1, 150, 150}, []bool{true, false, false}},
+		{CalendarEvent{0, 100, 200}, CalendarEvent{1, 150, 151}, []bool{true, true, false}},
+		{CalendarEvent{0, 100, 200}, CalendarEvent{1, 201, 300}, []bool{false, false, false}},
+	}
+	for _, c := range cases {
+		for i, mode := range boundaryModes {
+			if c.left.Overlaps(c.right, mode) != c.expected[i] || c.right.Overlaps(c.left, mode) != c.expected[i] {
+				t.Logf("%s with %s in %s mode should be %v", c.left.ToString(), c.right.ToString(), mode, c.expected[i])
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestFindOverlaps_Boundary(t *testing.T) {
+	// back to back events, event 3 is empty unless closed
+	events := CalendarEvents{{0, 0, 100}, {1, 100, 200}, {2, 200, 300}, {3, 150, 150}}
+	expected := map[BoundaryMode]int{BoundaryClosed: 3, BoundaryHalfOpen: 0, BoundaryOpen: 0}
+	for _, mode := range boundaryModes {
+		for algorithm := AlgorithmSweep; algorithm <= AlgorithmAuto; algorithm++ {
+			ret, err := FindOverlaps(events, Options{Algorithm: algorithm, Boundary: mode})
+			if err != nil || len(ret) != expected[mode] {
+				t.Logf("%s in %s mode should find %d pairs instead of %v, %v", algorithm, mode, expected[mode], ret, err)
+				t.FailNow()
+			}
+		}
+	}
+
+	for _, mode := range boundaryModes {
+		baseline := brutalPairsMode(testEvents, mode)
+		sortPairs(baseline)
+		for algorithm := AlgorithmSweep; algorithm <= AlgorithmAuto; algorithm++ {
+			ret, err := FindOverlaps(testEvents, Options{Algorithm: algorithm, Boundary: mode, BucketSize: 50})
+			if err != nil || !isSamePairs(ret, baseline) {
+				t.Logf("%s in %s mode is different from baseline: %v", algorithm, mode, err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestBoundary_Stateful(t *testing.T) {
+	for _, mode := range boundaryModes {
+		baseline := brutalPairsMode(testEvents, mode)
+
+		detector := NewOverlapDetectorMode(mode)
+		bucket, err := NewBucketIndexMode(50, mode)
+		if err != nil {
+			t.Logf("bucket index of size 50 should be fine: %s", err)
+			t.FailNow()
+		}
+		var bucketPairs []CalendarPair
+		for _, evt := range testEvents {
+			detector.Add(evt)
+			bucketPairs = append(bucketPairs, bucket.Add(evt)...)
+		}
+		if !isSamePairs(detector.Pairs(), baseline) {
+			t.Logf("detector in %s mode is different from baseline", mode)
+			t.FailNow()
+		}
+		if !isSamePairs(bucketPairs, baseline) {
+			t.Logf("bucket index in %s mode is different from baseline", mode)
+			t.FailNow()
+		}
+
+		events := sortedTestEvents()
+		idx := 0
+		it := NewOverlapIteratorMode(func() (CalendarEvent, bool) {
+			if idx >= len(events) {
+				return CalendarEvent{}, false
+			}
+			idx++
+			return events[idx-1], true
+		}, mode)
+		var streamPairs []CalendarPair
+		for it.Next() {
+			streamPairs = append(streamPairs, it.Pair())
+		}
+		if it.Err() != nil || !isSamePairs(streamPairs, baseline) {
+			t.Logf("iterator in %s mode is different from baseline: %v", mode, it.Err())
+			t.FailNow()
+		}
+
+		tree := NewIntervalTreeMode(testEvents, mode)
+		for start := int64(0); start < 1000; start += 41 {
+			query := CalendarEvent{-1, start, start + 10}
+			var expected []CalendarEvent
+			for _, evt := range testEvents {
+				if query.Overlaps(evt, mode) {
+					expected = append(expected, evt)
+				}
+			}
+			if ret := tree.Overlapping(start, start+10); !sameEventIds(ret, expected) {
+				t.Logf("tree in %s mode overlapping %d -> %d is different from baseline", mode, start, start+10)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestSegment_Bounds(t *testing.T) {
+	events := CalendarEvents{{0, 100, 200}, {1, 200, 300}}
+	for _, mode := range boundaryModes {
+		closed := mode.Normalize(events)
+		sort.Sort(closed)
+		seg := Segment{closed[0].Start, closed[len(closed)-1].End, nil}
+		start, end := seg.Bounds(mode)
+		if start != 100 || end != 300 {
+			t.Logf("segment in %s mode should be 100 -> 300 instead of %d -> %d", mode, start, end)
+			t.FailNow()
+		}
+	}
+	if ret := BoundaryHalfOpen.Normalize(CalendarEvents{{0, 10, 10}, {1, 10, 11}}); len(ret) != 1 || ret[0].End != 10 {
+		t.Logf("empty events should be dropped: %v", ret)
+		t.FailNow()
+	}
+}
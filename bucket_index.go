@@ -0,0 +1,163 @@
+package calendar
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// BucketIndex cuts the timeline into buckets of same size, each event is put into every bucket it covers
+// two overlapping events always share at least one bucket, which is the bucket of the later start
+// Complexity is close to O(n) when most events only cover a few buckets, so size should be
+// similar to the usual event length, see ChooseBucketSize
+// events covering more than longBuckets buckets are kept aside and checked one by one, so a few
+// long events among short ones do not fill thousands of buckets
+type BucketIndex struct {
+	size int64
+	mode BoundaryMode
+	// the original events and the closed version used for checking, see BoundaryMode
+	evts    CalendarEvents
+	closed  CalendarEvents
+	buckets map[int64][]int
+	long    []int
+}
+
+// events covering more buckets than this are long ones
+const longBuckets = 4
+
+var errorInvalidBucketSize = errors.New("invalid bucket size")
+
+// size should be positive
+func NewBucketIndex(size int64) (*BucketIndex, error) {
+	return NewBucketIndexMode(size, BoundaryClosed)
+}
+
+// same as NewBucketIndex but events are treated in given boundary mode
+func NewBucketIndexMode(size int64, mode BoundaryMode) (*BucketIndex, error) {
+	if size <= 0 {
+		return nil, errors.Wrapf(errorInvalidBucketSize, "%d", size)
+	}
+	return newBucketIndex(size, mode), nil
+}
+
+func newBucketIndex(size int64, mode BoundaryMode) *BucketIndex {
+	return &BucketIndex{size: size, mode: mode, buckets: make(map[int64][]int)}
+}
+
+// build the index with the size picked by ChooseBucketSize
+func NewBucketIndexFor(evts CalendarEvents) *BucketIndex {
+	index := newBucketIndex(ChooseBucketSize(evts), BoundaryClosed)
+	for _, evt := range evts {
+		index.Add(evt)
+	}
+	return index
+}
+
+// pick the bucket size from the 75th percentile of event lengths
+// so most events only cover one or two buckets while long ones do not dominate
+func ChooseBucketSize(evts CalendarEvents) int64 {
+	if len(evts) == 0 {
+		return 1
+	}
+	lengths := make([]int64, len(evts))
+	for i := range evts {
+		lengths[i] = evts[i].End - evts[i].Start
+		if lengths[i] < 0 {
+			// overflow of events spanning most of int64
+			lengths[i] = math.MaxInt64
+		}
+	}
+	sort.Slice(lengths, func(i, j int) bool {
+		return lengths[i] < lengths[j]
+	})
+	if size := lengths[len(lengths)*3/4]; size < math.MaxInt64 {
+		return size + 1
+	}
+	return math.MaxInt64
+}
+
+func (c *BucketIndex) Size() int64 {
+	return c.size
+}
+
+func (c *BucketIndex) Len() int {
+	return len(c.evts)
+}
+
+// floor division so negative timestamps still go into the right bucket
+func (c *BucketIndex) bucket(value int64) int64 {
+	ret := value / c.size
+	if value%c.size != 0 && value < 0 {
+		ret--
+	}
+	return ret
+}
+
+// add the event and return the pairs with events added before, FirstId always smaller
+func (c *BucketIndex) Add(evt CalendarEvent) (ret []CalendarPair) {
+	idx := len(c.evts)
+	c.evts = append(c.evts, evt)
+	closed, ok := c.mode.Closed(evt)
+	c.closed = append(c.closed, closed)
+	// empty events never overlap, so they are not put into any bucket
+	if !ok {
+		return
+	}
+	for _, other := range c.overlapping(closed) {
+		ret = append(ret, newCalendarPair(c.evts[other].Id, evt.Id))
+	}
+	first, last := c.bucket(closed.Start), c.bucket(closed.End)
+	if last-first >= longBuckets {
+		c.long = append(c.long, idx)
+		return
+	}
+	for k := first; k <= last; k++ {
+		c.buckets[k] = append(c.buckets[k], idx)
+	}
+	return
+}
+
+// return the events overlaps with start -> end in the order they were added
+func (c *BucketIndex) Overlapping(start, end int64) (ret []CalendarEvent) {
+	query, ok := c.mode.Closed(CalendarEvent{Start: start, End: end})
+	if !ok {
+		return
+	}
+	for _, idx := range c.overlapping(query) {
+		ret = append(ret, c.evts[idx])
+	}
+	return
+}
+
+// evt must be closed already
+func (c *BucketIndex) overlapping(evt CalendarEvent) (ret []int) {
+	seen := make(map[int]bool)
+	check := func(indexes []int) {
+		for _, idx := range indexes {
+			if seen[idx] {
+				continue
+			}
+			seen[idx] = true
+			if evt.isOverlap(c.closed[idx]) {
+				ret = append(ret, idx)
+			}
+		}
+	}
+	first, last := c.bucket(evt.Start), c.bucket(evt.End)
+	if last-first >= int64(len(c.buckets)) {
+		// a long query, walking the buckets there are is shorter than walking its range
+		for k, indexes := range c.buckets {
+			if k >= first && k <= last {
+				check(indexes)
+			}
+		}
+	} else {
+		for k := first; k <= last; k++ {
+			check(c.buckets[k])
+		}
+	}
+	check(c.long)
+	sort.Ints(ret)
+	return
+}
@@ -0,0 +1,107 @@
+package calendar
+
+import (
+	"math"
+	"testing"
+)
+
+func TestChooseBucketSize(t *testing.T) {
+	if size := ChooseBucketSize(nil); size != 1 {
+		t.Logf("empty events should have size 1 instead of %d", size)
+		t.FailNow()
+	}
+	events := CalendarEvents{{0, 0, 10}, {1, 0, 20}, {2, 0, 30}, {3, 0, 10000}}
+	if size := ChooseBucketSize(events); size != 10001 {
+		t.Logf("size should be 10001 instead of %d", size)
+		t.FailNow()
+	}
+	events = append(events, CalendarEvent{4, 0, 10}, CalendarEvent{5, 0, 10}, CalendarEvent{6, 0, 10}, CalendarEvent{7, 0, 10})
+	if size := ChooseBucketSize(events); size != 31 {
+		t.Logf("size should be 31 instead of %d", size)
+		t.FailNow()
+	}
+	// lengths close to int64 do not overflow into a negative size
+	events = CalendarEvents{{0, math.MinInt64 / 2, math.MaxInt64 / 2}, {1, 0, math.MaxInt64}}
+	if size := ChooseBucketSize(events); size != math.MaxInt64 {
+		t.Logf("size should be %d instead of %d", int64(math.MaxInt64), size)
+		t.FailNow()
+	}
+}
+
+func TestBucketIndex_Overlapping(t *testing.T) {
+	for _, size := range []int64{0, -10} {
+		if _, err := NewBucketIndex(size); err == nil {
+			t.Logf("bucket size %d should be refused", size)
+			t.FailNow()
+		}
+		if _, err := FindOverlapPairBucket(testEvents, size); err == nil {
+			t.Logf("bucket size %d should be refused by FindOverlapPairBucket too", size)
+			t.FailNow()
+		}
+	}
+	pairs, _ := FindOverlapPairBucket(testEvents, 50)
+	for i := 1; i < len(pairs); i++ {
+		if pairs[i-1].FirstId > pairs[i].FirstId ||
+			pairs[i-1].FirstId == pairs[i].FirstId && pairs[i-1].SecondId >= pairs[i].SecondId {
+			t.Logf("bucket pairs %v, %v are not sorted", pairs[i-1], pairs[i])
+			t.FailNow()
+		}
+	}
+	index, err := NewBucketIndex(10)
+	if err != nil {
+		t.Logf("bucket size 10 should be fine: %s", err)
+		t.FailNow()
+	}
+	index.Add(CalendarEvent{0, -25, -5})
+	index.Add(CalendarEvent{1, 0, 100})
+	index.Add(CalendarEvent{2, 95, 95})
+	if ret := index.Overlapping(-5, -5); len(ret) != 1 || ret[0].Id != 0 {
+		t.Logf("it should only overlap event 0 instead of %v", ret)
+		t.FailNow()
+	}
+	if ret := index.Overlapping(50, 95); len(ret) != 2 || ret[0].Id != 1 || ret[1].Id != 2 {
+		t.Logf("it should overlap event 1, 2 instead of %v", ret)
+		t.FailNow()
+	}
+
+	index = NewBucketIndexFor(testEvents)
+	if index.Len() != len(testEvents) {
+		t.Logf("index should have %d events instead of %d", len(testEvents), index.Len())
+		t.FailNow()
+	}
+	for start := int64(-10); start < 1100; start += 13 {
+		if ret, baseline := index.Overlapping(start, start+30), bruteOverlapping(testEvents, start, start+30); !sameEventIds(ret, baseline) {
+			t.Logf("overlapping %d -> %d got %d events, baseline %d", start, start+30, len(ret), len(baseline))
+			t.FailNow()
+		}
+	}
+}
+
+func TestBucketIndex_LongEvents(t *testing.T) {
+	index, _ := NewBucketIndex(10)
+	events := CalendarEvents{{0, 0, 1000000}, {1, -500, -400}}
+	for i := 2; i < 50; i++ {
+		events = append(events, CalendarEvent{i, int64(i) * 37, int64(i)*37 + 15})
+	}
+	var pairs []CalendarPair
+	for _, evt := range events {
+		pairs = append(pairs, index.Add(evt)...)
+	}
+	// the long event should not be spread over 100000 buckets
+	if len(index.buckets) > 200 {
+		t.Logf("index should have at most 200 buckets instead of %d", len(index.buckets))
+		t.FailNow()
+	}
+	if !isSamePairs(pairs, brutalPairsMode(events, BoundaryClosed)) {
+		t.Logf("pairs with a long event are different from baseline")
+		t.FailNow()
+	}
+	if ret, baseline := index.Overlapping(-1000, 2000000), bruteOverlapping(events, -1000, 2000000); !sameEventIds(ret, baseline) {
+		t.Logf("long query got %d events, baseline %d", len(ret), len(baseline))
+		t.FailNow()
+	}
+	if ret := index.Overlapping(5000, 6000); len(ret) != 1 || ret[0].Id != 0 {
+		t.Logf("it should only overlap event 0 instead of %v", ret)
+		t.FailNow()
+	}
+}
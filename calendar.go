@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"sort"
+	"time"
 )
 
 // the calendar event struct
@@ -13,8 +14,9 @@ type CalendarEvent struct {
 	End   int64 // end date in unix timestamp
 }
 
+// e.g. Calendar event #3: 2026-10-18 09:00 UTC -> 09:15 UTC
 func (c *CalendarEvent) ToString() string {
-	return fmt.Sprintf("Calendar event: %d -> %d", c.Start, c.End)
+	return fmt.Sprintf("Calendar event #%d: %s", c.Id, formatRange(c.Start, c.End, time.UTC))
 }
 func (c *CalendarEvent) IsValid() bool {
 	return c.Start >= 0 && c.Start <= c.End
@@ -78,9 +80,9 @@ func (c CalendarEvents) Less(i, j int) bool {
 	return c[i].Start < c[j].Start
 }
 
-// we sort the events for faster check
-func FindOverlapPairsSort(evts CalendarEvents) (ret []CalendarPair) {
-	length := len(evts)
+// we sort a copy of the events for faster check, the input keeps its order
+func FindOverlapPairsSort(input CalendarEvents) (ret []CalendarPair) {
+	length := len(input)
 
 	// if the input is less then 2 events then there must be no overlaps
 	if length < 2 {
@@ -88,6 +90,8 @@ func FindOverlapPairsSort(evts CalendarEvents) (ret []CalendarPair) {
 	}
 
 	// for better performance we can sort the event by start time
+	evts := make(CalendarEvents, length)
+	copy(evts, input)
 	sort.Sort(evts)
 
 	for i := 0; i < length; i++ {
@@ -111,6 +115,62 @@ func FindOverlapPairsSort(evts CalendarEvents) (ret []CalendarPair) {
 
 }
 
+// an endpoint of the event at idx
+type sweepPoint struct {
+	value int64
+	isEnd bool
+	idx   int
+}
+
+// sweep line over the sorted start / end endpoints while keeping the active events
+// Complexity O(n log n + k) where k is the number of pairs
+func FindOverlapPairsSweep(evts CalendarEvents) (ret []CalendarPair) {
+	length := len(evts)
+
+	// if the input is less then 2 events then there must be no overlaps
+	if length < 2 {
+		return
+	}
+
+	points := make([]sweepPoint, 0, length*2)
+	for i := range evts {
+		points = append(points, sweepPoint{evts[i].Start, false, i}, sweepPoint{evts[i].End, true, i})
+	}
+	// start points go before end points with same value, since intervals are closed
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].value != points[j].value {
+			return points[i].value < points[j].value
+		}
+		return !points[i].isEnd && points[j].isEnd
+	})
+
+	// active events and the position of each event inside active, so removal is O(1)
+	active := make([]int, 0, length)
+	position := make([]int, length)
+	for _, point := range points {
+		if point.isEnd {
+			pos := position[point.idx]
+			last := active[len(active)-1]
+			active[pos] = last
+			position[last] = pos
+			active = active[:len(active)-1]
+			continue
+		}
+		evt := &evts[point.idx]
+		for _, idx := range active {
+			// we make sure firstId always smaller
+			if evts[idx].Id < evt.Id {
+				ret = append(ret, CalendarPair{evts[idx].Id, evt.Id})
+			} else {
+				ret = append(ret, CalendarPair{evt.Id, evts[idx].Id})
+			}
+		}
+		position[point.idx] = len(active)
+		active = append(active, point.idx)
+	}
+	return
+}
+
 // Segment struct
 
 type Segment struct {
@@ -253,7 +313,7 @@ func (c Segments) AddSeg(idx int, id int, start, end int64, pairs map[CalendarPa
 		} else {
 			// adding the pair into hash map
 			for _, v := range seg.Ids {
-				pair := CalendarPair{FirstId: v, SecondId: id}
+				pair := newCalendarPair(v, id)
 				if _, ok := pairs[pair]; !ok {
 					pairs[pair] = true
 				}
@@ -289,15 +349,24 @@ func (c Segments) AddSeg(idx int, id int, start, end int64, pairs map[CalendarPa
 }
 
 func FindOverlapPairsSeg(evts CalendarEvents) (ret []CalendarPair) {
-	length := len(evts)
-
 	// if the input is less then 2 events then there must be no overlaps
-	if length < 2 {
+	if len(evts) < 2 {
+		return
+	}
+	_, ret = FindSegments(evts)
+	return
+}
+
+// the timeline of evts as segments in order, each one with ids of events covering it
+// time covered by no event has no segment, pairs are the overlaps same as FindOverlapPairsSeg
+func FindSegments(evts CalendarEvents) (segs Segments, ret []CalendarPair) {
+	length := len(evts)
+	if length == 0 {
 		return
 	}
 	pairs := make(map[CalendarPair]bool)
 
-	segs := Segments{{Start: evts[0].Start, End: evts[0].End, Ids: []int{evts[0].Id}}}
+	segs = Segments{{Start: evts[0].Start, End: evts[0].End, Ids: []int{evts[0].Id}}}
 	for i := 1; i < length; i++ {
 		evt := evts[i]
 		// find the segment which include start
@@ -316,7 +385,7 @@ func FindOverlapPairsSeg(evts CalendarEvents) (ret []CalendarPair) {
 		} else {
 			// within
 			for _, id := range segs[idx].Ids {
-				pair := CalendarPair{FirstId: id, SecondId: evt.Id}
+				pair := newCalendarPair(id, evt.Id)
 				if _, ok := pairs[pair]; !ok {
 					pairs[pair] = true
 				}
@@ -338,71 +407,22 @@ func FindOverlapPairsSeg(evts CalendarEvents) (ret []CalendarPair) {
 	for pair := range pairs {
 		ret = append(ret, pair)
 	}
+	// map iteration order is random, sort it so the output is stable
+	sortPairs(ret)
 	return
 }
 
-type bucketSeg struct {
-	evts []*CalendarEvent
-}
-
-func (c *bucketSeg) Add(event *CalendarEvent) {
-	c.evts = append(c.evts, event)
-}
-
-func (c *bucketSeg) GetPair(event *CalendarEvent) (ret []CalendarPair) {
-	for _, e := range c.evts {
-		if e.isOverlap(*event) {
-			ret = append(ret, CalendarPair{e.Id, event.Id})
-		}
-	}
-	return
-}
-
-func (c *CalendarEvent) getHash(size int64) (start, end int64) {
-	start = c.Start / size
-	end = c.End / size
-	return
-}
-
-func FindOverlapPairBucket(evts CalendarEvents, size int64) (ret []CalendarPair) {
-	buckets := make(map[int64]*bucketSeg)
-	pairs := make(map[CalendarPair]bool)
-	for idx := range evts {
-		start, end := evts[idx].getHash(size)
-		if bucket, ok := buckets[start]; !ok {
-			buckets[start] = &bucketSeg{[]*CalendarEvent{&evts[idx]}}
-		} else {
-			newPairs := bucket.GetPair(&evts[idx])
-			for i := 0; i < len(newPairs); i++ {
-				pairs[newPairs[i]] = true
-			}
-			bucket.Add(&evts[idx])
-		}
-		if end > start {
-			if bucket, ok := buckets[end]; !ok {
-				buckets[end] = &bucketSeg{[]*CalendarEvent{&evts[idx]}}
-			} else {
-				newPairs := bucket.GetPair(&evts[idx])
-				for i := 0; i < len(newPairs); i++ {
-					pairs[newPairs[i]] = true
-				}
-				bucket.Add(&evts[idx])
-			}
-			for k := start + 1; k < end; k++ {
-				if bucket, ok := buckets[end]; !ok {
-					buckets[end] = &bucketSeg{[]*CalendarEvent{&evts[idx]}}
-				} else {
-					for i := 0; i < len(bucket.evts); i++ {
-						pairs[CalendarPair{bucket.evts[i].Id, evts[idx].Id}] = true
-					}
-					bucket.Add(&evts[idx])
-				}
-			}
-		}
-
+// put events into time buckets and only compare events sharing a bucket
+// size should be positive as for NewBucketIndex, ChooseBucketSize picks one for the events
+// pairs are ordered by FirstId then SecondId as FindOverlapPairsSeg does
+func FindOverlapPairBucket(evts CalendarEvents, size int64) (ret []CalendarPair, err error) {
+	index, err := NewBucketIndex(size)
+	if err != nil {
+		return
 	}
-	for pair := range pairs {
-		ret = append(ret, pair)
+	for _, evt := range evts {
+		ret = append(ret, index.Add(evt)...)
 	}
+	sortPairs(ret)
 	return
 }
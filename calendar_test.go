@@ -534,7 +534,7 @@ func TestFindOverlapPairsSeg(t *testing.T) {
 
 func TestFindOverlapPairBucket(t *testing.T) {
 	events := []CalendarEvent{{0, 0, 100}, {1, 0, 110}}
-	ret := FindOverlapPairBucket(events, 50)
+	ret, _ := FindOverlapPairBucket(events, 50)
 	if len(ret) != 1 {
 		t.Logf("it should return 1 pair instead of %d", len(ret))
 		t.FailNow()
@@ -545,7 +545,7 @@ func TestFindOverlapPairBucket(t *testing.T) {
 	}
 
 	events = []CalendarEvent{{0, 0, 100}, {1, 101, 200}, {2, 0, 2000}}
-	ret = FindOverlapPairBucket(events, 50)
+	ret, _ = FindOverlapPairBucket(events, 50)
 	if len(ret) != 2 {
 		t.Logf("it should return 2 pair instead of %d", len(ret))
 		t.FailNow()
@@ -553,6 +553,35 @@ func TestFindOverlapPairBucket(t *testing.T) {
 
 }
 
+func TestFindOverlapPairBucket2(t *testing.T) {
+	// long events spanning many buckets and events starting in the middle buckets
+	events := []CalendarEvent{{0, 0, 1000}, {1, 120, 130}, {2, 260, 270}, {3, 250, 900}, {4, 990, 2000}}
+	ret, _ := FindOverlapPairBucket(events, 50)
+	baseline := FindOverlapPairsBrutal(events)
+	if isSame, leftNotFound, rightNotFound := compareTwoPairArrays(ret, baseline); !isSame {
+		printPairs(events, leftNotFound)
+		fmt.Println("=========================")
+		printPairs(events, rightNotFound)
+		t.FailNow()
+	}
+
+	for _, size := range []int64{ChooseBucketSize(testEvents), 1, 7, 50, 200, 5000} {
+		events = make([]CalendarEvent, len(testEvents))
+		copy(events, testEvents)
+		ret, _ = FindOverlapPairBucket(events, size)
+		for _, pair := range ret {
+			if pair.FirstId >= pair.SecondId {
+				t.Logf("pair %v is not normalized", pair)
+				t.FailNow()
+			}
+		}
+		if !isSamePairs(ret, FindOverlapPairsBrutal(events)) {
+			t.Logf("bucket with size %d is different from baseline", size)
+			t.FailNow()
+		}
+	}
+}
+
 func TestFindOverlapPairsSort(t *testing.T) {
 	events := make([]CalendarEvent, len(testEvents))
 	copy(events, testEvents)
@@ -582,6 +611,29 @@ func TestFindOverlapPairsSeg2(t *testing.T) {
 	}
 }
 
+func TestFindOverlapPairsSweep(t *testing.T) {
+	events := []CalendarEvent{{0, 0, 100}, {1, 100, 200}, {2, 201, 300}}
+	ret := FindOverlapPairsSweep(events)
+	if len(ret) != 1 || ret[0].FirstId != 0 || ret[0].SecondId != 1 {
+		t.Logf("it should return pair 0, 1 instead of %v", ret)
+		t.FailNow()
+	}
+
+	events = make([]CalendarEvent, len(testEvents))
+	copy(events, testEvents)
+	ret = FindOverlapPairsSweep(events)
+	baseline := FindOverlapPairsBrutal(events)
+	if isSame, leftNotFound, rightNotFound := compareTwoPairArrays(ret, baseline); !isSame {
+		fmt.Println("==== sweep ====")
+		printPairs(events, leftNotFound)
+		fmt.Println("########")
+		fmt.Println("==== baseline ====")
+		printPairs(events, rightNotFound)
+		fmt.Println("########")
+		t.FailNow()
+	}
+}
+
 //
 func Benchmark_brutal(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -606,3 +658,9 @@ func Benchmark_sort(b *testing.B) {
 		FindOverlapPairsBrutal(testEvents)
 	}
 }
+
+func Benchmark_sweep(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindOverlapPairsSweep(testEvents)
+	}
+}
@@ -0,0 +1,89 @@
+// calendard serves events and overlap detection of the calendar package over HTTP
+//
+//	calendard -addr :8080 -data /var/lib/calendard -reject
+//
+// without -data events are kept in memory and lost on exit
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	calendar "github.com/waters222/calendar-test"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	data := flag.String("data", "", "directory of the event store, in memory when empty")
+	reject := flag.Bool("reject", false, "reject writes overlapping other events instead of reporting them")
+	zone := flag.String("zone", "UTC", "zone floating and all-day events are resolved in")
+	shutdown := flag.Duration("shutdown", 10*time.Second, "how long requests in flight may take on exit")
+	flag.Parse()
+
+	loc, err := time.LoadLocation(*zone)
+	if err != nil {
+		log.Fatalf("invalid zone: %s", err)
+	}
+	// conflicts the store could not check all the way go to the log
+	opts := calendar.StoreOptions{Zone: loc, Logf: log.Printf}
+	if *reject {
+		opts.Conflicts = calendar.ConflictReject
+	}
+	var store calendar.Store = calendar.NewMemoryStore(opts)
+	if *data != "" {
+		fileStore, err := calendar.OpenFileStore(*data, calendar.FileStoreOptions{StoreOptions: opts})
+		if err != nil {
+			log.Fatalf("open store: %s", err)
+		}
+		defer fileStore.Close()
+		store = fileStore
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(store, loc).routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Printf("listen: %s", err)
+		return
+	}
+	log.Printf("listening on %s", ln.Addr())
+	if err := serve(ctx, srv, ln, *shutdown); err != nil {
+		log.Printf("serve: %s", err)
+	}
+}
+
+// serve until ctx is done, then stop taking requests and let the ones in flight finish
+// within timeout, the store can be closed once it returns
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	// Serve returns ErrServerClosed right after Shutdown starts
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
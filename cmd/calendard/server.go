@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	calendar "github.com/waters222/calendar-test"
+)
+
+// bodies larger than this are refused
+const maxBody = 10 << 20
+
+// longest window of a list or free busy, series are expanded over the whole window
+const maxWindow = 366 * 24 * 3600
+
+// most occurrences and longest UNTIL of a series, longer ones should have no end
+const maxCount = 10000
+const maxSeries = 10 * 366 * 24 * 3600
+
+// most events of one /overlaps request, the pairs of n events are up to n * n / 2
+const maxOverlapEvents = 2000
+
+// the body of every error response, {"error": {"code": ..., "message": ...}}
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// ids of the events overlapping, only for code overlap
+	Conflicts []int `json:"conflicts,omitempty"`
+	// version in the store, only for code version_conflict
+	Version int64 `json:"version,omitempty"`
+}
+
+func (c *apiError) Error() string {
+	return c.Message
+}
+
+func badRequest(code, format string, args ...interface{}) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// turn errors of the store into responses, conflicts go with ErrOverlap
+func storeError(err error, conflicts []int) *apiError {
+	cause := errors.Cause(err)
+	if versionErr, ok := cause.(*calendar.VersionConflictError); ok {
+		return &apiError{Status: http.StatusPreconditionFailed, Code: "version_conflict", Message: err.Error(), Version: versionErr.Actual}
+	}
+	switch cause {
+	case calendar.ErrNotFound:
+		return &apiError{Status: http.StatusNotFound, Code: "not_found", Message: err.Error()}
+	case calendar.ErrOverlap:
+		return &apiError{Status: http.StatusConflict, Code: "overlap", Message: err.Error(), Conflicts: conflicts}
+	}
+	return &apiError{Status: http.StatusInternalServerError, Code: "internal", Message: err.Error()}
+}
+
+type server struct {
+	store calendar.Store
+	// zone floating and all-day events are resolved in for free busy
+	zone *time.Location
+}
+
+func newServer(store calendar.Store, zone *time.Location) *server {
+	if zone == nil {
+		zone = time.UTC
+	}
+	return &server{store: store, zone: zone}
+}
+
+func (c *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/events", c.handle(c.events))
+	mux.Handle("/events/", c.handle(c.event))
+	mux.Handle("/overlaps", c.handle(c.overlaps))
+	mux.Handle("/freebusy", c.handle(c.freeBusy))
+	return mux
+}
+
+// write the error of fn as JSON, fn writes the response itself when it succeeds
+func (c *server) handle(fn func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+		apiErr, ok := err.(*apiError)
+		if !ok {
+			apiErr = &apiError{Status: http.StatusInternalServerError, Code: "internal", Message: err.Error()}
+		}
+		if apiErr.Status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", apiErr.Message)
+			apiErr.Message = fmt.Sprintf("%s is not allowed, use %s", r.Method, apiErr.Message)
+		}
+		writeJSON(w, apiErr.Status, struct {
+			Error *apiError `json:"error"`
+		}{apiErr})
+	})
+}
+
+func methodNotAllowed(allowed ...string) *apiError {
+	return &apiError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: strings.Join(allowed, ", ")}
+}
+
+// encoded before anything is written, so a failure can still be sent as an error
+// a client gone while writing is not an error of ours
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+	return nil
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(v); err != nil {
+		return badRequest("invalid_json", "invalid body: %s", err)
+	}
+	if decoder.More() {
+		return badRequest("invalid_json", "invalid body: more than one value")
+	}
+	return nil
+}
+
+// the event of the body, the store decides the id and version
+func readEvent(r *http.Request) (calendar.Event, error) {
+	var evt calendar.Event
+	if err := readJSON(r, &evt); err != nil {
+		return evt, err
+	}
+	if !evt.IsValid() {
+		return evt, badRequest("invalid_event", "event %s should start after 1970 and end after it starts", evt.ToString())
+	}
+	if rule := evt.Recurrence; rule != nil {
+		if rule.Count > maxCount {
+			return evt, badRequest("invalid_event", "COUNT %d is more than %d, leave it out for a series without end", rule.Count, maxCount)
+		}
+		if !rule.Until.IsZero() && rule.Until.Unix()-evt.Start > maxSeries {
+			return evt, badRequest("invalid_event", "UNTIL is more than %d years after the start, leave it out for a series without end", maxSeries/(366*24*3600))
+		}
+	}
+	return evt, nil
+}
+
+// unix time of an RFC 3339 query parameter
+func queryTime(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, badRequest("invalid_query", "missing %s", name)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, badRequest("invalid_query", "invalid %s %q, expect RFC 3339", name, value)
+	}
+	return t.Unix(), nil
+}
+
+// the start and end query parameters, both are required and at most maxWindow apart
+func queryWindow(r *http.Request) (start, end int64, err error) {
+	if start, err = queryTime(r, "start"); err != nil {
+		return
+	}
+	if end, err = queryTime(r, "end"); err != nil {
+		return
+	}
+	if end < start {
+		err = badRequest("invalid_query", "end is before start")
+	} else if end-start > maxWindow {
+		err = badRequest("invalid_query", "window longer than %d days", maxWindow/(24*3600))
+	}
+	return
+}
+
+type eventResponse struct {
+	Event     calendar.Event `json:"event"`
+	Conflicts []int          `json:"conflicts"`
+}
+
+func writeEvent(w http.ResponseWriter, status int, evt calendar.Event, conflicts []int) error {
+	w.Header().Set("ETag", evt.ETag())
+	if conflicts == nil {
+		conflicts = []int{}
+	}
+	return writeJSON(w, status, eventResponse{evt, conflicts})
+}
+
+// GET /events?start=&end= lists events in the window of at most maxWindow, POST /events creates one
+func (c *server) events(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		start, end, err := queryWindow(r)
+		if err != nil {
+			return err
+		}
+		evts, err := c.store.List(start, end)
+		if err != nil {
+			return storeError(err, nil)
+		}
+		if evts == nil {
+			evts = calendar.Events{}
+		}
+		return writeJSON(w, http.StatusOK, struct {
+			Events calendar.Events `json:"events"`
+		}{evts})
+	case http.MethodPost:
+		evt, err := readEvent(r)
+		if err != nil {
+			return err
+		}
+		created, conflicts, err := c.store.Create(evt)
+		if err != nil {
+			return storeError(err, conflicts)
+		}
+		w.Header().Set("Location", fmt.Sprintf("/events/%d", created.Id))
+		return writeEvent(w, http.StatusCreated, created, conflicts)
+	}
+	return methodNotAllowed(http.MethodGet, http.MethodPost)
+}
+
+// GET, PUT and DELETE /events/{id}, PUT and DELETE take the ETag as If-Match
+func (c *server) event(w http.ResponseWriter, r *http.Request) error {
+	value := strings.TrimPrefix(r.URL.Path, "/events/")
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return &apiError{Status: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf("no event %q", value)}
+	}
+	version := int64(0)
+	if match := r.Header.Get("If-Match"); match != "" && match != "*" {
+		if version, err = calendar.ParseETag(match); err != nil {
+			return badRequest("invalid_header", "invalid If-Match %q", match)
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		evt, err := c.store.Get(id)
+		if err != nil {
+			return storeError(err, nil)
+		}
+		return writeEvent(w, http.StatusOK, evt, nil)
+	case http.MethodPut:
+		evt, err := readEvent(r)
+		if err != nil {
+			return err
+		}
+		evt.Id = id
+		evt.Version = version
+		updated, conflicts, err := c.store.Update(evt)
+		if err != nil {
+			return storeError(err, conflicts)
+		}
+		return writeEvent(w, http.StatusOK, updated, conflicts)
+	case http.MethodDelete:
+		if err := c.store.Delete(id, version); err != nil {
+			return storeError(err, nil)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return methodNotAllowed(http.MethodGet, http.MethodPut, http.MethodDelete)
+}
+
+type overlapsRequest struct {
+	// name of calendar.Algorithm, sweep by default, may be given as ?algorithm= as well
+	Algorithm string                   `json:"algorithm"`
+	Events    []calendar.CalendarEvent `json:"events"`
+}
+
+type overlapsResponse struct {
+	Algorithm string                  `json:"algorithm"`
+	Pairs     []calendar.CalendarPair `json:"pairs"`
+}
+
+// POST /overlaps finds the overlapping pairs of at most maxOverlapEvents events in the body,
+// nothing is stored
+func (c *server) overlaps(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return methodNotAllowed(http.MethodPost)
+	}
+	var req overlapsRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	if len(req.Events) > maxOverlapEvents {
+		return badRequest("too_many_events", "%d events, at most %d are allowed", len(req.Events), maxOverlapEvents)
+	}
+	name := req.Algorithm
+	if query := r.URL.Query().Get("algorithm"); query != "" {
+		name = query
+	}
+	var opts calendar.Options
+	if name != "" {
+		algorithm, err := calendar.ParseAlgorithm(name)
+		if err != nil {
+			return badRequest("invalid_algorithm", "%s", err)
+		}
+		opts.Algorithm = algorithm
+	}
+	ids := make(map[int]bool, len(req.Events))
+	for i := range req.Events {
+		evt := &req.Events[i]
+		if !evt.IsValid() {
+			return badRequest("invalid_event", "event %d %s should start after 1970 and end after it starts", i, evt.ToString())
+		}
+		if ids[evt.Id] {
+			return badRequest("invalid_event", "event %d has id %d used before", i, evt.Id)
+		}
+		ids[evt.Id] = true
+	}
+	pairs, err := calendar.FindOverlaps(req.Events, opts)
+	if err != nil {
+		return err
+	}
+	if pairs == nil {
+		pairs = []calendar.CalendarPair{}
+	}
+	return writeJSON(w, http.StatusOK, overlapsResponse{opts.Algorithm.String(), pairs})
+}
+
+type intervalJSON struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func intervalsJSON(intervals []calendar.Interval) []intervalJSON {
+	ret := make([]intervalJSON, len(intervals))
+	for i, interval := range intervals {
+		ret[i] = intervalJSON{
+			time.Unix(interval.Start, 0).UTC().Format(time.RFC3339),
+			time.Unix(interval.End, 0).UTC().Format(time.RFC3339),
+		}
+	}
+	return ret
+}
+
+// GET /freebusy?start=&end= returns the busy blocks of the stored events and the free gaps
+// between them within the window of at most maxWindow, both ends are included, all-day events do not make busy
+func (c *server) freeBusy(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed(http.MethodGet)
+	}
+	start, end, err := queryWindow(r)
+	if err != nil {
+		return err
+	}
+	evts, err := c.store.List(start, end)
+	if err != nil {
+		return storeError(err, nil)
+	}
+	var instants calendar.CalendarEvents
+	for _, occ := range calendar.ExpandEvents(evts, start, end, c.zone) {
+		if !occ.AllDay {
+			instants = append(instants, occ.Instants(c.zone))
+		}
+	}
+	busy, free := calendar.FreeBusy(instants, start, end)
+	return writeJSON(w, http.StatusOK, struct {
+		Busy []intervalJSON `json:"busy"`
+		Free []intervalJSON `json:"free"`
+	}{intervalsJSON(busy), intervalsJSON(free)})
+}
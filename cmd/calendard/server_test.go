@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	calendar "github.com/waters222/calendar-test"
+)
+
+type response struct {
+	status int
+	header http.Header
+	body   map[string]interface{}
+}
+
+func request(t *testing.T, handler http.Handler, method, target, body string, header ...string) response {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	ret := response{status: rec.Code, header: rec.Header()}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &ret.body); err != nil {
+			t.Logf("%s %s returned no JSON: %s", method, target, rec.Body.String())
+			t.FailNow()
+		}
+	}
+	return ret
+}
+
+// code of the error body
+func (c response) code() string {
+	if e, ok := c.body["error"].(map[string]interface{}); ok {
+		return e["code"].(string)
+	}
+	return ""
+}
+
+func TestEvents(t *testing.T) {
+	handler := newServer(calendar.NewMemoryStore(calendar.StoreOptions{Conflicts: calendar.ConflictReject}), nil).routes()
+
+	res := request(t, handler, "POST", "/events", `{"start":"2026-10-19T09:00:00Z","end":"2026-10-19T10:00:00Z","title":"Planning"}`)
+	if res.status != http.StatusCreated || res.header.Get("Location") != "/events/0" || res.header.Get("ETag") != `"1"` {
+		t.Logf("create failed: %d %v %v", res.status, res.header, res.body)
+		t.FailNow()
+	}
+	res = request(t, handler, "POST", "/events", `{"start":"2026-10-19T09:30:00Z","end":"2026-10-19T10:30:00Z"}`)
+	if res.status != http.StatusConflict || res.code() != "overlap" || !reflect.DeepEqual(res.body["error"].(map[string]interface{})["conflicts"], []interface{}{0.0}) {
+		t.Logf("expect overlap but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	for _, body := range []string{
+		`{"start":"2026-10-19T11:00:00Z","end":"2026-10-19T10:00:00Z"}`,
+		`{"start":"1969-12-31T00:00:00Z","end":"2026-10-19T10:00:00Z"}`,
+		`{"start":"2026-10-20T09:00:00Z","end":"2026-10-20T10:00:00Z","recurrence":"FREQ=DAILY;COUNT=200000"}`,
+		`{"start":"2026-10-20T09:00:00Z","end":"2026-10-20T10:00:00Z","recurrence":"FREQ=DAILY;UNTIL=29991231T000000Z"}`,
+	} {
+		if res := request(t, handler, "POST", "/events", body); res.status != http.StatusBadRequest || res.code() != "invalid_event" {
+			t.Logf("%s should be invalid but got %d %v", body, res.status, res.body)
+			t.FailNow()
+		}
+	}
+	if res := request(t, handler, "POST", "/events", `{"start":`); res.status != http.StatusBadRequest || res.code() != "invalid_json" {
+		t.Logf("expect invalid JSON but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+
+	res = request(t, handler, "GET", "/events/0", "")
+	if res.status != http.StatusOK || res.body["event"].(map[string]interface{})["title"] != "Planning" {
+		t.Logf("get failed: %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "GET", "/events/7", ""); res.status != http.StatusNotFound || res.code() != "not_found" {
+		t.Logf("expect not found but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+
+	// the second writer holds a stale ETag
+	moved := `{"start":"2026-10-19T13:00:00Z","end":"2026-10-19T14:00:00Z","title":"Planning"}`
+	res = request(t, handler, "PUT", "/events/0", moved, "If-Match", `"1"`)
+	if res.status != http.StatusOK || res.header.Get("ETag") != `"2"` {
+		t.Logf("update failed: %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	res = request(t, handler, "PUT", "/events/0", moved, "If-Match", `"1"`)
+	if res.status != http.StatusPreconditionFailed || res.code() != "version_conflict" || res.body["error"].(map[string]interface{})["version"] != 2.0 {
+		t.Logf("expect version conflict but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "PUT", "/events/0", moved, "If-Match", `2`); res.status != http.StatusBadRequest {
+		t.Logf("expect bad If-Match but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+
+	request(t, handler, "POST", "/events", `{"start":"2026-10-20T09:00:00Z","end":"2026-10-20T10:00:00Z"}`)
+	res = request(t, handler, "GET", "/events?start=2026-10-19T00:00:00Z&end=2026-10-19T23:59:59Z", "")
+	if evts := res.body["events"].([]interface{}); res.status != http.StatusOK || len(evts) != 1 {
+		t.Logf("expect 1 event but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "GET", "/events?start=2026-01-01T00:00:00Z&end=2026-12-31T00:00:00Z", ""); len(res.body["events"].([]interface{})) != 2 {
+		t.Logf("expect 2 events but got %v", res.body)
+		t.FailNow()
+	}
+	for _, query := range []string{"", "?start=yesterday&end=2026-10-19T00:00:00Z", "?start=2026-10-19T00:00:00Z", "?start=2026-10-19T00:00:00Z&end=2028-10-19T00:00:00Z"} {
+		if res := request(t, handler, "GET", "/events"+query, ""); res.status != http.StatusBadRequest || res.code() != "invalid_query" {
+			t.Logf("%s: expect invalid query but got %d %v", query, res.status, res.body)
+			t.FailNow()
+		}
+	}
+
+	if res := request(t, handler, "DELETE", "/events/0", "", "If-Match", `"1"`); res.status != http.StatusPreconditionFailed {
+		t.Logf("expect version conflict but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "DELETE", "/events/0", "", "If-Match", `"2"`); res.status != http.StatusNoContent {
+		t.Logf("delete failed: %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "DELETE", "/events/0", ""); res.status != http.StatusNotFound {
+		t.Logf("expect not found but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "PATCH", "/events/1", "{}"); res.status != http.StatusMethodNotAllowed || res.header.Get("Allow") != "GET, PUT, DELETE" {
+		t.Logf("expect method not allowed but got %d %v", res.status, res.header)
+		t.FailNow()
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	handler := newServer(calendar.NewMemoryStore(calendar.StoreOptions{}), nil).routes()
+	body := `{"events":[
+		{"id":1,"start":"2026-10-19T09:00:00Z","end":"2026-10-19T10:00:00Z"},
+		{"id":2,"start":"2026-10-19T09:30:00Z","end":"2026-10-19T11:00:00Z"},
+		{"id":3,"start":"2026-10-19T10:30:00Z","end":"2026-10-19T12:00:00Z"}]}`
+	for _, algorithm := range []string{"", "brutal", "seg", "bucket", "parallel", "auto"} {
+		res := request(t, handler, "POST", "/overlaps?algorithm="+algorithm, body)
+		expect := []interface{}{
+			map[string]interface{}{"first": 1.0, "second": 2.0},
+			map[string]interface{}{"first": 2.0, "second": 3.0},
+		}
+		if res.status != http.StatusOK || !reflect.DeepEqual(res.body["pairs"], expect) {
+			t.Logf("%s: wrong pairs %d %v", algorithm, res.status, res.body)
+			t.FailNow()
+		}
+	}
+	if res := request(t, handler, "POST", "/overlaps", `{"algorithm":"sort","events":[]}`); res.body["algorithm"] != "sort" || len(res.body["pairs"].([]interface{})) != 0 {
+		t.Logf("expect no pairs by sort but got %v", res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "POST", "/overlaps?algorithm=magic", body); res.status != http.StatusBadRequest || res.code() != "invalid_algorithm" {
+		t.Logf("expect invalid algorithm but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	for _, events := range []string{
+		`[{"id":1,"start":"2026-10-19T09:00:00Z","end":"2026-10-19T08:00:00Z"}]`,
+		`[{"id":1,"start":"2026-10-19T09:00:00Z","end":"2026-10-19T10:00:00Z"},{"id":1,"start":"2026-10-19T09:00:00Z","end":"2026-10-19T10:00:00Z"}]`,
+	} {
+		if res := request(t, handler, "POST", "/overlaps", `{"events":`+events+`}`); res.status != http.StatusBadRequest || res.code() != "invalid_event" {
+			t.Logf("expect invalid event but got %d %v", res.status, res.body)
+			t.FailNow()
+		}
+	}
+	events := make([]string, maxOverlapEvents+1)
+	for i := range events {
+		events[i] = fmt.Sprintf(`{"id":%d,"start":"2026-10-19T09:00:00Z","end":"2026-10-19T10:00:00Z"}`, i)
+	}
+	if res := request(t, handler, "POST", "/overlaps?algorithm=brutal", `{"events":[`+strings.Join(events, ",")+`]}`); res.status != http.StatusBadRequest || res.code() != "too_many_events" {
+		t.Logf("expect too many events but got %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	if res := request(t, handler, "GET", "/overlaps", ""); res.status != http.StatusMethodNotAllowed {
+		t.Logf("expect method not allowed but got %d", res.status)
+		t.FailNow()
+	}
+}
+
+func TestFreeBusy(t *testing.T) {
+	store := calendar.NewMemoryStore(calendar.StoreOptions{})
+	handler := newServer(store, nil).routes()
+	request(t, handler, "POST", "/events", `{"start":"2026-10-19T09:00:00Z","end":"2026-10-19T10:00:00Z"}`)
+	request(t, handler, "POST", "/events", `{"start":"2026-10-19T09:30:00Z","end":"2026-10-19T11:00:00Z"}`)
+	request(t, handler, "POST", "/events", `{"start":"2026-10-19","end":"2026-10-19","allDay":true}`)
+	request(t, handler, "POST", "/events", `{"start":"2026-10-19T12:00:00Z","end":"2026-10-19T13:00:00Z","recurrence":"FREQ=DAILY;COUNT=3"}`)
+
+	res := request(t, handler, "GET", "/freebusy?start=2026-10-19T08:00:00Z&end=2026-10-20T12:30:00Z", "")
+	busy := []interface{}{
+		map[string]interface{}{"start": "2026-10-19T09:00:00Z", "end": "2026-10-19T11:00:00Z"},
+		map[string]interface{}{"start": "2026-10-19T12:00:00Z", "end": "2026-10-19T13:00:00Z"},
+		map[string]interface{}{"start": "2026-10-20T12:00:00Z", "end": "2026-10-20T12:30:00Z"},
+	}
+	if res.status != http.StatusOK || !reflect.DeepEqual(res.body["busy"], busy) {
+		t.Logf("wrong busy %d %v", res.status, res.body)
+		t.FailNow()
+	}
+	free := res.body["free"].([]interface{})
+	if len(free) != 3 || free[0].(map[string]interface{})["end"] != "2026-10-19T08:59:59Z" {
+		t.Logf("wrong free %v", free)
+		t.FailNow()
+	}
+	for _, query := range []string{"?start=2026-10-19T08:00:00Z", "?start=2026-10-19T08:00:00Z&end=9999-12-31T00:00:00Z"} {
+		if res := request(t, handler, "GET", "/freebusy"+query, ""); res.status != http.StatusBadRequest || res.code() != "invalid_query" {
+			t.Logf("%s: expect invalid query but got %d %v", query, res.status, res.body)
+			t.FailNow()
+		}
+	}
+}
+
+// an endless series is only ever expanded within a bounded window
+func TestEndlessSeries(t *testing.T) {
+	handler := newServer(calendar.NewMemoryStore(calendar.StoreOptions{}), nil).routes()
+	request(t, handler, "POST", "/events", `{"start":"2026-10-19T09:00:00Z","end":"2026-10-19T09:15:00Z","recurrence":"FREQ=DAILY"}`)
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		res := request(t, handler, "GET", "/events?start=2026-10-19T00:00:00Z&end=2027-10-19T00:00:00Z", "")
+		if res.status != http.StatusOK || len(res.body["events"].([]interface{})) != 1 {
+			t.Errorf("expect the series but got %d %v", res.status, res.body)
+		}
+		res = request(t, handler, "GET", "/freebusy?start=2026-10-19T00:00:00Z&end=2027-10-19T00:00:00Z", "")
+		if res.status != http.StatusOK || len(res.body["busy"].([]interface{})) != 365 {
+			t.Errorf("expect a busy block a day but got %d", res.status)
+		}
+		if res := request(t, handler, "GET", "/events?start=2026-10-19T00:00:00Z&end=9999-12-31T00:00:00Z", ""); res.status != http.StatusBadRequest {
+			t.Errorf("expect the window refused but got %d %v", res.status, res.body)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Logf("endless series should not block the server")
+		t.FailNow()
+	}
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	started := make(chan bool)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Logf("listen failed: %s", err)
+		t.FailNow()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: mux}, ln, 5*time.Second)
+	}()
+
+	replied := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			replied <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		replied <- string(body)
+	}()
+	<-started
+	// like SIGTERM while the request is in flight
+	cancel()
+	if body := <-replied; body != "done" {
+		t.Logf("request in flight should finish but got %s", body)
+		t.FailNow()
+	}
+	if err := <-served; err != nil {
+		t.Logf("serve failed: %s", err)
+		t.FailNow()
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/slow"); err == nil {
+		t.Logf("server should not take requests after shutdown")
+		t.FailNow()
+	}
+}
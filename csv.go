@@ -0,0 +1,379 @@
+package calendar
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// header of each column, empty means the column is not used
+// Start and End are required, without Id the events are numbered by row from 0
+// with Date the start and end columns only have the time of the day, an end before the start
+// is on the next day
+type CSVColumns struct {
+	Id          string
+	Date        string
+	Start       string
+	End         string
+	Title       string
+	Location    string
+	Description string
+}
+
+var DefaultCSVColumns = CSVColumns{
+	Id:          "id",
+	Start:       "start",
+	End:         "end",
+	Title:       "title",
+	Location:    "location",
+	Description: "description",
+}
+
+type CSVOptions struct {
+	// DefaultCSVColumns when zero, headers are matched case insensitive, other columns
+	// are kept in Metadata by their header
+	Columns CSVColumns
+	// layout of start and end, time.RFC3339 by default or 15:04 with a date column
+	Layout string
+	// layout of the date column, 2006-01-02 by default
+	DateLayout string
+	// zone of times without offset, also the zone of the events, UTC when nil
+	Zone *time.Location
+	// field separator, ',' by default
+	Comma rune
+}
+
+func (c *CSVOptions) columns() CSVColumns {
+	if c.Columns == (CSVColumns{}) {
+		return DefaultCSVColumns
+	}
+	return c.Columns
+}
+
+func (c *CSVOptions) layout(withDate bool) string {
+	if c.Layout != "" {
+		return c.Layout
+	}
+	if withDate {
+		return "15:04"
+	}
+	return time.RFC3339
+}
+
+func (c *CSVOptions) dateLayout() string {
+	if c.DateLayout == "" {
+		return "2006-01-02"
+	}
+	return c.DateLayout
+}
+
+func (c *CSVOptions) zone() *time.Location {
+	if c.Zone == nil {
+		return time.UTC
+	}
+	return c.Zone
+}
+
+func (c *CSVOptions) comma() rune {
+	if c.Comma == 0 {
+		return ','
+	}
+	return c.Comma
+}
+
+// every bad row of ReadCSV, in order of lines
+type CSVError struct {
+	Rows []*ParseError
+}
+
+func (c *CSVError) Error() string {
+	reasons := make([]string, len(c.Rows))
+	for i, row := range c.Rows {
+		reasons[i] = row.Error()
+	}
+	return fmt.Sprintf("%d bad rows: %s", len(c.Rows), strings.Join(reasons, "; "))
+}
+
+var errorMissingColumn = errors.New("missing column")
+var errorCSVDateColumn = errors.New("event does not fit the date column")
+
+// ReadCSV reads events with a header line, bad rows do not stop the reading, the events of
+// the good rows are returned together with a *CSVError listing every bad row
+// an unreadable file or header is returned as *ParseError
+func ReadCSV(r io.Reader, opts CSVOptions) (Events, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.comma()
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, csvParseError(err)
+	}
+
+	// index of each header, lower case
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	wanted := opts.columns()
+	index := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		if i, ok := columns[strings.ToLower(name)]; ok {
+			return i
+		}
+		return -1
+	}
+	idCol, dateCol, startCol, endCol := index(wanted.Id), index(wanted.Date), index(wanted.Start), index(wanted.End)
+	titleCol, locationCol, descriptionCol := index(wanted.Title), index(wanted.Location), index(wanted.Description)
+	for _, required := range []struct {
+		name string
+		col  int
+	}{{wanted.Start, startCol}, {wanted.End, endCol}, {wanted.Date, dateCol}} {
+		if required.name != "" && required.col < 0 {
+			return nil, &ParseError{1, errors.Wrapf(errorMissingColumn, "%q", required.name)}
+		}
+	}
+	known := map[int]bool{idCol: true, dateCol: true, startCol: true, endCol: true, titleCol: true, locationCol: true, descriptionCol: true}
+
+	layout := opts.layout(dateCol >= 0)
+	if dateCol >= 0 {
+		layout = opts.dateLayout() + " " + layout
+	}
+	zone := opts.zone()
+
+	var ret Events
+	bad := &CSVError{}
+	ids := make(map[int]int)
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a broken quote can not be skipped, keep what is read so far
+			if _, ok := err.(*csv.ParseError); ok {
+				bad.Rows = append(bad.Rows, csvParseError(err))
+				break
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+		fail := func(format string, args ...interface{}) {
+			bad.Rows = append(bad.Rows, parseErrorf(line, format, args...).(*ParseError))
+		}
+
+		evt := Event{Zone: zone}
+		evt.Id = row
+		if idCol >= 0 {
+			value := field(idCol)
+			if evt.Id, err = strconv.Atoi(value); err != nil {
+				fail("invalid id %q", value)
+				continue
+			}
+		}
+		if other, ok := ids[evt.Id]; ok {
+			fail("id %d is already used on line %d", evt.Id, other)
+			continue
+		}
+		parse := func(col int) (time.Time, bool) {
+			value := field(col)
+			if value == "" {
+				fail("missing %s", header[col])
+				return time.Time{}, false
+			}
+			if dateCol >= 0 {
+				value = field(dateCol) + " " + value
+			}
+			t, err := time.ParseInLocation(layout, value, zone)
+			if err != nil {
+				fail("invalid %s %q, expect layout %q", header[col], value, layout)
+				return time.Time{}, false
+			}
+			return t, true
+		}
+		start, ok := parse(startCol)
+		if !ok {
+			continue
+		}
+		end, ok := parse(endCol)
+		if !ok {
+			continue
+		}
+		// overnight, e.g. 22:00 to 06:00
+		if dateCol >= 0 && end.Before(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		evt.Start, evt.End = start.Unix(), end.Unix()
+		if !evt.IsValid() {
+			if evt.Start < 0 {
+				fail("start is before 1970")
+			} else {
+				fail("end is before start")
+			}
+			continue
+		}
+		evt.Title = field(titleCol)
+		evt.Location = field(locationCol)
+		evt.Description = field(descriptionCol)
+		for i, name := range header {
+			if !known[i] && field(i) != "" {
+				if evt.Metadata == nil {
+					evt.Metadata = make(map[string]string)
+				}
+				evt.Metadata[strings.TrimSpace(name)] = field(i)
+			}
+		}
+		ids[evt.Id] = line
+		ret = append(ret, evt)
+	}
+	if len(bad.Rows) > 0 {
+		return ret, bad
+	}
+	return ret, nil
+}
+
+func csvParseError(err error) *ParseError {
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		return &ParseError{parseErr.Line, parseErr.Err}
+	}
+	return &ParseError{1, err}
+}
+
+// WriteCSV writes the events with a header, times are written in opts.Zone
+// with a date column it has the start date and the start and end only the time of the day,
+// an event ReadCSV would not read back the same, e.g. with seconds or longer than a day,
+// fails the whole write before anything is written
+// metadata keys become extra columns after the known ones, in order of name
+func WriteCSV(w io.Writer, evts Events, opts CSVOptions) error {
+	columns := opts.columns()
+	zone := opts.zone()
+	layout := opts.layout(columns.Date != "")
+	if columns.Date != "" {
+		for i := range evts {
+			if err := checkCSVDate(&evts[i], zone, opts.dateLayout(), layout); err != nil {
+				return err
+			}
+		}
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for i := range evts {
+		for key := range evts[i].Metadata {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var header []string
+	for _, name := range []string{columns.Id, columns.Date, columns.Start, columns.End, columns.Title, columns.Location, columns.Description} {
+		if name != "" {
+			header = append(header, name)
+		}
+	}
+	header = append(header, keys...)
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma()
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i := range evts {
+		evt := &evts[i]
+		start, end := evt.TimesIn(zone)
+		var record []string
+		add := func(name, value string) {
+			if name != "" {
+				record = append(record, value)
+			}
+		}
+		add(columns.Id, strconv.Itoa(evt.Id))
+		add(columns.Date, start.Format(opts.dateLayout()))
+		add(columns.Start, start.Format(layout))
+		add(columns.End, end.Format(layout))
+		add(columns.Title, evt.Title)
+		add(columns.Location, evt.Location)
+		add(columns.Description, evt.Description)
+		for _, key := range keys {
+			record = append(record, evt.Metadata[key])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// read the times of evt back as ReadCSV does with a date column and check nothing is lost
+func checkCSVDate(evt *Event, zone *time.Location, dateLayout, layout string) error {
+	start, end := evt.TimesIn(zone)
+	date := start.Format(dateLayout)
+	readStart, err1 := time.ParseInLocation(dateLayout+" "+layout, date+" "+start.Format(layout), zone)
+	readEnd, err2 := time.ParseInLocation(dateLayout+" "+layout, date+" "+end.Format(layout), zone)
+	if err1 == nil && err2 == nil && readEnd.Before(readStart) {
+		readEnd = readEnd.AddDate(0, 0, 1)
+	}
+	if err1 != nil || err2 != nil || !readStart.Equal(start) || !readEnd.Equal(end) {
+		return errors.Wrapf(errorCSVDateColumn, "event %d from %s to %s", evt.Id, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// WritePairsCSV writes the conflict pairs with the titles of both events and the time
+// they overlap, evts are used to look up the events and may be nil
+func WritePairsCSV(w io.Writer, evts Events, pairs []CalendarPair, opts CSVOptions) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma()
+	if err := writer.Write([]string{"first_id", "second_id", "first_title", "second_title", "overlap_start", "overlap_end"}); err != nil {
+		return err
+	}
+	index := evts.Index()
+	zone := opts.zone()
+	for _, pair := range pairs {
+		record := []string{strconv.Itoa(pair.FirstId), strconv.Itoa(pair.SecondId), "", "", "", ""}
+		first, ok1 := index[pair.FirstId]
+		second, ok2 := index[pair.SecondId]
+		if ok1 {
+			record[2] = first.Title
+		}
+		if ok2 {
+			record[3] = second.Title
+		}
+		if ok1 && ok2 {
+			a, b := first.Instants(zone), second.Instants(zone)
+			start, end := a.Start, a.End
+			if b.Start > start {
+				start = b.Start
+			}
+			if b.End < end {
+				end = b.End
+			}
+			record[4] = time.Unix(start, 0).In(zone).Format(opts.layout(false))
+			record[5] = time.Unix(end, 0).In(zone).Format(opts.layout(false))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,172 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestReadCSV(t *testing.T) {
+	input := strings.Join([]string{
+		"ID,Start,End,Title,Room",
+		"1,2026-10-18T09:00:00Z,2026-10-18T10:00:00Z,Standup,A",
+		"2,2026-10-18T09:30:00Z,2026-10-18T11:00:00Z,\"Review, weekly\",A",
+		"x,2026-10-18T09:30:00Z,2026-10-18T11:00:00Z,Bad id,B",
+		"3,2026-10-18 09:30,2026-10-18T11:00:00Z,Bad start,B",
+		"4,2026-10-18T12:00:00Z,2026-10-18T11:00:00Z,Backwards,B",
+		"1,2026-10-18T12:00:00Z,2026-10-18T13:00:00Z,Same id,B",
+		"5,,2026-10-18T13:00:00Z,No start,B",
+		"6,2026-10-18T12:00:00Z,2026-10-18T13:00:00Z,Lunch,C",
+	}, "\n")
+	evts, err := ReadCSV(strings.NewReader(input), CSVOptions{})
+	if len(evts) != 3 || evts[1].Title != "Review, weekly" || evts[2].Id != 6 || evts[0].Metadata["Room"] != "A" {
+		t.Logf("wrong events %v", evts)
+		t.FailNow()
+	}
+	if evts[0].Zone != time.UTC || evts[0].End-evts[0].Start != 3600 {
+		t.Logf("wrong times %s", evts[0].ToString())
+		t.FailNow()
+	}
+	csvErr, ok := err.(*CSVError)
+	if !ok || len(csvErr.Rows) != 5 {
+		t.Logf("expect 5 bad rows but got %v", err)
+		t.FailNow()
+	}
+	expect := []struct {
+		line   int
+		reason string
+	}{
+		{4, `invalid id "x"`},
+		{5, `invalid Start "2026-10-18 09:30"`},
+		{6, "end is before start"},
+		{7, "id 1 is already used on line 2"},
+		{8, "missing Start"},
+	}
+	for i, row := range csvErr.Rows {
+		if row.Line != expect[i].line || !strings.Contains(row.Err.Error(), expect[i].reason) {
+			t.Logf("expect line %d %s but got %s", expect[i].line, expect[i].reason, row)
+			t.FailNow()
+		}
+	}
+	pairs := FindOverlapPairsSweep(evts.CalendarEvents())
+	if len(pairs) != 1 || pairs[0] != (CalendarPair{1, 2}) {
+		t.Logf("wrong pairs %v", pairs)
+		t.FailNow()
+	}
+}
+
+func TestReadCSVMapping(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	input := "Room;Day;From;To;Booked by\n" +
+		"A;18/10/2026;09:00;10:30;Ann\n" +
+		"A;01/11/2026;01:30;03:00;Bob\n"
+	opts := CSVOptions{
+		Columns:    CSVColumns{Date: "day", Start: "from", End: "to", Location: "room"},
+		Layout:     "15:04",
+		DateLayout: "02/01/2006",
+		Zone:       newYork,
+		Comma:      ';',
+	}
+	evts, err := ReadCSV(strings.NewReader(input), opts)
+	if err != nil || len(evts) != 2 {
+		t.Logf("read failed: %v", err)
+		t.FailNow()
+	}
+	if evts[0].Id != 0 || evts[1].Id != 1 || evts[0].Location != "A" || evts[1].Metadata["Booked by"] != "Bob" {
+		t.Logf("wrong events %v", evts)
+		t.FailNow()
+	}
+	if time.Unix(evts[0].Start, 0).UTC().Hour() != 13 || evts[1].Duration() != 150*time.Minute {
+		t.Logf("wrong times %s and %s", evts[0].ToString(), evts[1].ToString())
+		t.FailNow()
+	}
+
+	var b bytes.Buffer
+	if err := WriteCSV(&b, evts, opts); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	if !strings.HasPrefix(b.String(), "day;from;to;room;Booked by\n18/10/2026;09:00;10:30;A;Ann\n") {
+		t.Logf("wrong output\n%s", b.String())
+		t.FailNow()
+	}
+	again, err := ReadCSV(bytes.NewReader(b.Bytes()), opts)
+	if err != nil || !sameImportedEvents(evts, again) {
+		t.Logf("round trip changed the events %v %v", again, err)
+		t.FailNow()
+	}
+
+	if _, err := ReadCSV(strings.NewReader("start,finish\n"), CSVOptions{}); err == nil || err.(*ParseError).Line != 1 {
+		t.Logf("missing end column should fail on line 1: %v", err)
+		t.FailNow()
+	}
+	evts, err = ReadCSV(strings.NewReader("start,end\n1970-01-01T00:00:00Z,1970-01-01T00:01:00Z\n\"broken,1970\n"), CSVOptions{})
+	if csvErr, ok := err.(*CSVError); !ok || len(evts) != 1 || csvErr.Rows[0].Line != 3 {
+		t.Logf("broken quote should be a bad row: %v", err)
+		t.FailNow()
+	}
+}
+
+// with a date column the times are 15:04 by default and an end before the start is overnight
+func TestReadCSVDate(t *testing.T) {
+	opts := CSVOptions{Columns: CSVColumns{Date: "date", Start: "start", End: "end"}}
+	input := "date,start,end\n2024-03-01,09:00,10:00\n2024-03-01,22:00,06:00\n"
+	evts, err := ReadCSV(strings.NewReader(input), opts)
+	if err != nil || len(evts) != 2 {
+		t.Logf("read failed: %v", err)
+		t.FailNow()
+	}
+	if start := time.Unix(evts[0].Start, 0).UTC(); start != time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC) || evts[0].Duration() != time.Hour {
+		t.Logf("wrong event %s", evts[0].ToString())
+		t.FailNow()
+	}
+	if end := time.Unix(evts[1].End, 0).UTC(); end != time.Date(2024, 3, 2, 6, 0, 0, 0, time.UTC) {
+		t.Logf("overnight event should end the next day %s", evts[1].ToString())
+		t.FailNow()
+	}
+
+	var b bytes.Buffer
+	if err := WriteCSV(&b, evts, opts); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	if b.String() != input {
+		t.Logf("expect\n%s\nbut got\n%s", input, b.String())
+		t.FailNow()
+	}
+
+	// seconds and a second day are lost with a date column, so nothing is written
+	for _, evt := range []Event{
+		NewZonedEvent(0, time.Date(2024, 3, 1, 9, 0, 30, 0, time.UTC), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)),
+		NewZonedEvent(0, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 3, 10, 0, 0, 0, time.UTC)),
+		NewZonedEvent(0, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)),
+	} {
+		b.Reset()
+		if err := WriteCSV(&b, Events{evts[0], evt}, opts); errors.Cause(err) != errorCSVDateColumn || b.Len() != 0 {
+			t.Logf("%s should not be written but got %v\n%s", evt.ToString(), err, b.String())
+			t.FailNow()
+		}
+	}
+}
+
+func TestWritePairsCSV(t *testing.T) {
+	evts := CalendarEvents{{0, 100, 200}, {1, 150, 250}}.Events()
+	evts[0].Title = "Standup"
+	evts[1].Title = "Review, weekly"
+	var b bytes.Buffer
+	pairs := []CalendarPair{{0, 1}, {0, 9}}
+	if err := WritePairsCSV(&b, evts, pairs, CSVOptions{}); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	expect := "first_id,second_id,first_title,second_title,overlap_start,overlap_end\n" +
+		"0,1,Standup,\"Review, weekly\",1970-01-01T00:02:30Z,1970-01-01T00:03:20Z\n" +
+		"0,9,Standup,,,\n"
+	if b.String() != expect {
+		t.Logf("expect\n%s\nbut got\n%s", expect, b.String())
+		t.FailNow()
+	}
+}
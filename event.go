@@ -0,0 +1,175 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reply of an attendee, names are the PARTSTAT values of RFC 5545
+type RSVPStatus int
+
+const (
+	RSVPNeedsAction RSVPStatus = iota
+	RSVPAccepted
+	RSVPDeclined
+	RSVPTentative
+)
+
+var rsvpNames = map[RSVPStatus]string{
+	RSVPNeedsAction: "NEEDS-ACTION",
+	RSVPAccepted:    "ACCEPTED",
+	RSVPDeclined:    "DECLINED",
+	RSVPTentative:   "TENTATIVE",
+}
+
+func (c RSVPStatus) String() string {
+	if name, ok := rsvpNames[c]; ok {
+		return name
+	}
+	return rsvpNames[RSVPNeedsAction]
+}
+
+// unknown names are treated as NEEDS-ACTION
+func ParseRSVPStatus(name string) RSVPStatus {
+	for status, v := range rsvpNames {
+		if strings.EqualFold(v, name) {
+			return status
+		}
+	}
+	return RSVPNeedsAction
+}
+
+func (c RSVPStatus) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *RSVPStatus) UnmarshalText(text []byte) error {
+	*c = ParseRSVPStatus(string(text))
+	return nil
+}
+
+type Attendee struct {
+	Name   string     `json:"name,omitempty"`
+	Email  string     `json:"email,omitempty"`
+	Status RSVPStatus `json:"status"`
+}
+
+func (c *Attendee) ToString() string {
+	if c.Name == "" {
+		return c.Email
+	} else if c.Email == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s <%s>", c.Name, c.Email)
+}
+
+// Event is a CalendarEvent with everything describing what it is
+// the embedded CalendarEvent is what the overlap functions work on
+type Event struct {
+	CalendarEvent
+	// globally unique id of iCalendar, empty when the event is not from or for it
+	UID         string
+	Title       string
+	Description string
+	Location    string
+	Organizer   Attendee
+	Attendees   []Attendee
+	Metadata    map[string]string
+	// zone the event is rendered in, nil means UTC
+	Zone *time.Location
+	// Start and End are wall clock without zone, see NewFloatingEvent
+	Floating bool
+	// date only event, see NewAllDayEvent
+	AllDay bool
+	// nil means not recurring, see Occurrences
+	Recurrence *RecurrenceRule
+	// extra and excluded occurrence starts, same encoding as Start
+	RDates  []time.Time
+	ExDates []time.Time
+	// modified instances keyed by the original start of the instance (RECURRENCE-ID)
+	Overrides map[int64]Event
+	// set by stores, 1 when created and one more on every update, 0 means never stored
+	Version int64
+}
+
+// human readable summary, e.g.
+// Standup (#3) 2026-10-18 09:00 UTC -> 09:15 UTC at Room 1, organized by Ann, 1/3 accepted
+func (c *Event) ToString() string {
+	var b strings.Builder
+	title := c.Title
+	if title == "" {
+		title = "Untitled event"
+	}
+	if c.AllDay {
+		first, last := c.Dates()
+		if first == last {
+			fmt.Fprintf(&b, "%s (#%d) %s all day", title, c.Id, first)
+		} else {
+			fmt.Fprintf(&b, "%s (#%d) %s -> %s all day", title, c.Id, first, last)
+		}
+	} else {
+		fmt.Fprintf(&b, "%s (#%d) %s", title, c.Id, formatRange(c.Start, c.End, c.zone()))
+	}
+	if c.Location != "" {
+		fmt.Fprintf(&b, " at %s", c.Location)
+	}
+	if organizer := c.Organizer.ToString(); organizer != "" {
+		fmt.Fprintf(&b, ", organized by %s", organizer)
+	}
+	if len(c.Attendees) > 0 {
+		fmt.Fprintf(&b, ", %d/%d accepted", c.countStatus(RSVPAccepted), len(c.Attendees))
+	}
+	return b.String()
+}
+
+func (c *Event) countStatus(status RSVPStatus) (ret int) {
+	for _, attendee := range c.Attendees {
+		if attendee.Status == status {
+			ret++
+		}
+	}
+	return
+}
+
+// find the attendee by email, case insensitive
+func (c *Event) Attendee(email string) (*Attendee, bool) {
+	for i := range c.Attendees {
+		if strings.EqualFold(c.Attendees[i].Email, email) {
+			return &c.Attendees[i], true
+		}
+	}
+	return nil, false
+}
+
+type Events []Event
+
+// the embedded CalendarEvents, ready for FindOverlapPairs* and FindOverlaps
+// floating and all-day events are kept as wall clock, use Instants to resolve them for a zone
+func (c Events) CalendarEvents() CalendarEvents {
+	ret := make(CalendarEvents, len(c))
+	for i := range c {
+		ret[i] = c[i].CalendarEvent
+	}
+	return ret
+}
+
+// map of event id to event, used to look up the events of CalendarPair
+func (c Events) Index() map[int]*Event {
+	ret := make(map[int]*Event, len(c))
+	for i := range c {
+		ret[c[i].Id] = &c[i]
+	}
+	return ret
+}
+
+// format start -> end, the date of end is skipped when it is on same day
+func formatRange(start, end int64, loc *time.Location) string {
+	startTime := time.Unix(start, 0).In(loc)
+	endTime := time.Unix(end, 0).In(loc)
+	layout := "2006-01-02 15:04 MST"
+	if startTime.YearDay() == endTime.YearDay() && startTime.Year() == endTime.Year() {
+		return fmt.Sprintf("%s -> %s", startTime.Format(layout), endTime.Format("15:04 MST"))
+	}
+	return fmt.Sprintf("%s -> %s", startTime.Format(layout), endTime.Format(layout))
+}
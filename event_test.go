@@ -0,0 +1,72 @@
+package calendar
+
+import (
+	"testing"
+)
+
+func TestEvent_ToString(t *testing.T) {
+	// 2026-10-18 09:00 UTC
+	evt := Event{
+		CalendarEvent: CalendarEvent{3, 1792314000, 1792314900},
+		Title:         "Standup",
+		Location:      "Room 1",
+		Organizer:     Attendee{Name: "Ann", Email: "ann@example.com"},
+		Attendees: []Attendee{
+			{Email: "bob@example.com", Status: RSVPAccepted},
+			{Email: "eve@example.com", Status: RSVPDeclined},
+		},
+	}
+	expected := "Standup (#3) 2026-10-18 09:00 UTC -> 09:15 UTC at Room 1, organized by Ann <ann@example.com>, 1/2 accepted"
+	if ret := evt.ToString(); ret != expected {
+		t.Logf("summary should be %q instead of %q", expected, ret)
+		t.FailNow()
+	}
+
+	evt = Event{CalendarEvent: CalendarEvent{4, 1792314000, 1792314000 + 86400}}
+	expected = "Untitled event (#4) 2026-10-18 09:00 UTC -> 2026-10-19 09:00 UTC"
+	if ret := evt.ToString(); ret != expected {
+		t.Logf("summary should be %q instead of %q", expected, ret)
+		t.FailNow()
+	}
+	expected = "Calendar event #4: 2026-10-18 09:00 UTC -> 2026-10-19 09:00 UTC"
+	if ret := evt.CalendarEvent.ToString(); ret != expected {
+		t.Logf("summary should be %q instead of %q", expected, ret)
+		t.FailNow()
+	}
+}
+
+func TestRSVPStatus(t *testing.T) {
+	for _, status := range []RSVPStatus{RSVPNeedsAction, RSVPAccepted, RSVPDeclined, RSVPTentative} {
+		if ParseRSVPStatus(status.String()) != status {
+			t.Logf("parse %s should return itself", status)
+			t.FailNow()
+		}
+	}
+	if ParseRSVPStatus("accepted") != RSVPAccepted || ParseRSVPStatus("maybe") != RSVPNeedsAction {
+		t.Log("parse should ignore case and fall back to NEEDS-ACTION")
+		t.FailNow()
+	}
+}
+
+func TestEvents_CalendarEvents(t *testing.T) {
+	events := Events{
+		{CalendarEvent: CalendarEvent{0, 0, 100}, Title: "first"},
+		{CalendarEvent: CalendarEvent{1, 100, 200}, Title: "second"},
+		{CalendarEvent: CalendarEvent{2, 300, 400}, Title: "third"},
+	}
+	ret := FindOverlapPairsSweep(events.CalendarEvents())
+	if len(ret) != 1 || ret[0] != (CalendarPair{0, 1}) {
+		t.Logf("it should return pair 0, 1 instead of %v", ret)
+		t.FailNow()
+	}
+	index := events.Index()
+	if index[ret[0].FirstId].Title != "first" || index[ret[0].SecondId].Title != "second" {
+		t.Log("index should map pair ids to events")
+		t.FailNow()
+	}
+	index[2].Attendees = append(index[2].Attendees, Attendee{Email: "Bob@example.com"})
+	if attendee, ok := events[2].Attendee("bob@EXAMPLE.com"); !ok || attendee.Email != "Bob@example.com" {
+		t.Log("attendee should be found by email")
+		t.FailNow()
+	}
+}
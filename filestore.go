@@ -0,0 +1,478 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SyncPolicy int
+
+const (
+	SyncAlways   SyncPolicy = iota // default, every write is fsynced before it returns
+	SyncInterval                   // the log is fsynced in the background every FileStoreOptions.SyncInterval
+	SyncNever                      // left to the OS, writes survive kill -9 but not a power loss
+)
+
+type FileStoreOptions struct {
+	StoreOptions
+	Sync SyncPolicy
+	// only for SyncInterval, a second by default
+	SyncInterval time.Duration
+	// records in the log before it is compacted into a snapshot, 1000 by default, < 0 never
+	SnapshotEvery int
+}
+
+const (
+	walFile         = "wal.log"
+	snapshotFile    = "snapshot.json"
+	snapshotTmpFile = "snapshot.json.tmp"
+)
+
+var errorCorruptLog = errors.New("corrupt log")
+var errorStoreClosed = errors.New("store is closed")
+
+// one line of the log, "<crc32 of json in hex> <json>\n"
+// a batch is one record, so it is either torn as a whole or not
+type walRecord struct {
+	Seq   int64       `json:"seq,omitempty"`
+	Op    string      `json:"op"`
+	Id    int         `json:"id"`
+	Event *Event      `json:"event,omitempty"`
+	Batch []walRecord `json:"batch,omitempty"`
+}
+
+const (
+	walPut    = "put"
+	walDelete = "delete"
+	walBatch  = "batch"
+)
+
+type snapshot struct {
+	// last record of the log included
+	Seq    int64  `json:"seq"`
+	NextId int    `json:"nextId"`
+	Events Events `json:"events"`
+}
+
+// FileStore is a Store kept in a directory, every write is appended to a log before it returns
+// and the log is compacted into a snapshot from time to time
+// opening the directory loads the snapshot and replays the log after it, a record torn by
+// a crash at the end of the log is dropped as its write never returned
+type FileStore struct {
+	// serializes writes, reads go straight to mem
+	mu   sync.Mutex
+	dir  string
+	opts FileStoreOptions
+	mem  *MemoryStore
+	log  *os.File
+	seq  int64
+	// records in the log since the last snapshot
+	logged int
+	// a failed write leaves the log in unknown state, so every write after fails too
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+func OpenFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// a snapshot not renamed yet is from a compaction which never finished
+	if err := os.Remove(filepath.Join(dir, snapshotTmpFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	c := &FileStore{dir: dir, opts: opts, mem: NewMemoryStore(opts.StoreOptions)}
+	if err := c.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, walFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c.log = log
+	if err := c.replay(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	if opts.Sync == SyncInterval {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.syncLoop()
+	}
+	return c, nil
+}
+
+func (c *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(c.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return errors.Wrapf(err, "read %s", snapshotFile)
+	}
+	for _, evt := range snap.Events {
+		c.mem.restore(evt)
+	}
+	c.mem.nextId = snap.NextId
+	c.seq = snap.Seq
+	return nil
+}
+
+// apply the records after the snapshot, the torn tail of the log is cut off
+func (c *FileStore) replay() error {
+	data, err := io.ReadAll(c.log)
+	if err != nil {
+		return err
+	}
+	offset := 0
+	for line := 1; offset < len(data); line++ {
+		end := bytes.IndexByte(data[offset:], '\n')
+		var record walRecord
+		var recordErr error
+		if end < 0 {
+			recordErr = errors.New("incomplete record")
+		} else {
+			recordErr = parseWALRecord(data[offset:offset+end], &record)
+		}
+		if recordErr != nil {
+			// only the last record can be torn by a crash, anything else is real damage
+			if end >= 0 && offset+end+1 < len(data) {
+				return &ParseError{line, errors.Wrapf(errorCorruptLog, "%s: %s", walFile, recordErr)}
+			}
+			if err := c.log.Truncate(int64(offset)); err != nil {
+				return err
+			}
+			return c.log.Sync()
+		}
+		offset += end + 1
+		// already in the snapshot when the log was not cut after it
+		if record.Seq <= c.seq {
+			continue
+		}
+		c.apply(&record)
+		c.seq = record.Seq
+		c.logged++
+	}
+	return nil
+}
+
+func (c *FileStore) apply(record *walRecord) {
+	switch record.Op {
+	case walPut:
+		c.mem.restore(*record.Event)
+	case walDelete:
+		c.mem.remove(record.Id)
+	case walBatch:
+		for i := range record.Batch {
+			c.apply(&record.Batch[i])
+		}
+	}
+}
+
+func parseWALRecord(line []byte, record *walRecord) error {
+	space := bytes.IndexByte(line, ' ')
+	if space < 0 {
+		return errors.New("missing checksum")
+	}
+	sum, err := strconv.ParseUint(string(line[:space]), 16, 32)
+	if err != nil || uint32(sum) != crc32.ChecksumIEEE(line[space+1:]) {
+		return errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(line[space+1:], record); err != nil {
+		return err
+	}
+	return checkWALRecord(record, line[space+1:])
+}
+
+func checkWALRecord(record *walRecord, line []byte) error {
+	switch {
+	case record.Op == walPut && record.Event != nil, record.Op == walDelete:
+		return nil
+	case record.Op == walBatch && len(record.Batch) > 0:
+		for i := range record.Batch {
+			if record.Batch[i].Op == walBatch {
+				return errors.Errorf("nested batch %q", line)
+			}
+			if err := checkWALRecord(&record.Batch[i], line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("unknown record %q", line)
+}
+
+func (c *FileStore) Create(evt Event) (Event, []int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return Event{}, nil, c.err
+	}
+	created, conflicts, err := c.mem.Create(evt)
+	if err != nil {
+		return Event{}, conflicts, err
+	}
+	if err := c.append(walRecord{Op: walPut, Id: created.Id, Event: &created}); err != nil {
+		c.mem.remove(created.Id)
+		return Event{}, nil, err
+	}
+	return created, conflicts, nil
+}
+
+func (c *FileStore) Get(id int) (Event, error) {
+	return c.mem.Get(id)
+}
+
+func (c *FileStore) Update(evt Event) (Event, []int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return Event{}, nil, c.err
+	}
+	previous, err := c.mem.Get(evt.Id)
+	if err != nil {
+		return Event{}, nil, err
+	}
+	updated, conflicts, err := c.mem.Update(evt)
+	if err != nil {
+		return Event{}, conflicts, err
+	}
+	if err := c.append(walRecord{Op: walPut, Id: updated.Id, Event: &updated}); err != nil {
+		c.mem.restore(previous)
+		return Event{}, nil, err
+	}
+	return updated, conflicts, nil
+}
+
+func (c *FileStore) Delete(id int, version int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	previous, err := c.mem.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := c.mem.Delete(id, version); err != nil {
+		return err
+	}
+	if err := c.append(walRecord{Op: walDelete, Id: id}); err != nil {
+		c.mem.restore(previous)
+		return err
+	}
+	return nil
+}
+
+// the whole batch is one record of the log
+func (c *FileStore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	previous := make(map[int]Event)
+	for _, op := range ops {
+		if op.Kind == BatchCreate {
+			continue
+		}
+		if _, ok := previous[op.Event.Id]; ok {
+			continue
+		}
+		if evt, err := c.mem.Get(op.Event.Id); err == nil {
+			previous[op.Event.Id] = evt
+		}
+	}
+	results, err := c.mem.Batch(ops)
+	if err != nil {
+		return nil, err
+	}
+	record := walRecord{Op: walBatch}
+	for i, op := range ops {
+		if op.Kind == BatchDelete {
+			record.Batch = append(record.Batch, walRecord{Op: walDelete, Id: op.Event.Id})
+		} else {
+			record.Batch = append(record.Batch, walRecord{Op: walPut, Id: results[i].Event.Id, Event: &results[i].Event})
+		}
+	}
+	if err := c.append(record); err != nil {
+		for i, op := range ops {
+			if op.Kind == BatchCreate {
+				c.mem.remove(results[i].Event.Id)
+			}
+		}
+		for _, evt := range previous {
+			c.mem.restore(evt)
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+func (c *FileStore) List(start, end int64) (Events, error) {
+	return c.mem.List(start, end)
+}
+
+func (c *FileStore) Len() int {
+	return c.mem.Len()
+}
+
+// write the record to the log and compact the log when it is long enough
+// the lock is held by the caller
+func (c *FileStore) append(record walRecord) error {
+	record.Seq = c.seq + 1
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+	if _, err := c.log.WriteString(line); err != nil {
+		c.err = errors.Wrap(err, "write log")
+		return c.err
+	}
+	if c.opts.Sync == SyncAlways {
+		if err := c.log.Sync(); err != nil {
+			c.err = errors.Wrap(err, "sync log")
+			return c.err
+		}
+	}
+	c.seq++
+	c.logged++
+	every := c.opts.SnapshotEvery
+	if every == 0 {
+		every = 1000
+	}
+	if every > 0 && c.logged >= every {
+		// the record is safe in the log, a failed compaction is tried again on the next write
+		c.snapshot()
+	}
+	return nil
+}
+
+// Snapshot compacts the log into a snapshot now
+func (c *FileStore) Snapshot() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.snapshot()
+}
+
+// the snapshot is written to a temporary file and renamed, so there is always a complete one
+// the log is cut only after that, records left by a crash in between are skipped by seq
+func (c *FileStore) snapshot() error {
+	evts, nextId := c.mem.dump()
+	data, err := json.Marshal(snapshot{c.seq, nextId, evts})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(c.dir, snapshotTmpFile)
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(c.dir); err != nil {
+		return err
+	}
+	if err := c.log.Truncate(0); err != nil {
+		return err
+	}
+	if err := c.log.Sync(); err != nil {
+		return err
+	}
+	c.logged = 0
+	return nil
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// make a rename in dir durable
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+func (c *FileStore) syncLoop() {
+	defer close(c.done)
+	interval := c.opts.SyncInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			if c.err == nil {
+				if err := c.log.Sync(); err != nil {
+					c.err = errors.Wrap(err, "sync log")
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Close syncs and closes the log, the store can not be used after
+func (c *FileStore) Close() error {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == errorStoreClosed {
+		return nil
+	}
+	err := c.log.Sync()
+	if closeErr := c.log.Close(); err == nil {
+		err = closeErr
+	}
+	c.err = errorStoreClosed
+	return err
+}
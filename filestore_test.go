@@ -0,0 +1,353 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// every event of the store by id
+func storeState(t *testing.T, store Store) map[int]string {
+	evts, err := store.List(0, endlessSpan)
+	if err != nil {
+		t.Logf("list failed: %s", err)
+		t.FailNow()
+	}
+	ret := make(map[int]string)
+	for i := range evts {
+		ret[evts[i].Id] = evts[i].ToString()
+	}
+	return ret
+}
+
+func openFileStore(t *testing.T, dir string, opts FileStoreOptions) *FileStore {
+	store, err := OpenFileStore(dir, opts)
+	if err != nil {
+		t.Logf("open failed: %s", err)
+		t.FailNow()
+	}
+	return store
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	opts := FileStoreOptions{SnapshotEvery: 5}
+	store := openFileStore(t, dir, opts)
+	var _ Store = store
+	for i := 0; i < 7; i++ {
+		evt := NewZonedEvent(0, time.Date(2026, 10, 19, 9+i, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 9+i, 30, 0, 0, time.UTC))
+		evt.Title = fmt.Sprintf("Meeting %d", i)
+		evt.Metadata = map[string]string{"room": "A"}
+		if _, _, err := store.Create(evt); err != nil {
+			t.Logf("create failed: %s", err)
+			t.FailNow()
+		}
+	}
+	evt, _ := store.Get(3)
+	evt.Title = "Moved"
+	evt.Start += 600
+	if _, _, err := store.Update(evt); err != nil {
+		t.Logf("update failed: %s", err)
+		t.FailNow()
+	}
+	if err := store.Delete(6, 0); err != nil {
+		t.Logf("delete failed: %s", err)
+		t.FailNow()
+	}
+	expect := storeState(t, store)
+	if err := store.Close(); err != nil {
+		t.Logf("close failed: %s", err)
+		t.FailNow()
+	}
+	if _, _, err := store.Create(evt); errors.Cause(err) != errorStoreClosed {
+		t.Logf("expect closed but got %v", err)
+		t.FailNow()
+	}
+
+	// 9 writes with a snapshot after the fifth, so 4 records are left in the log
+	data, _ := os.ReadFile(filepath.Join(dir, walFile))
+	if lines := strings.Count(string(data), "\n"); lines != 4 {
+		t.Logf("expect 4 records in the log but got %d", lines)
+		t.FailNow()
+	}
+	store = openFileStore(t, dir, opts)
+	if state := storeState(t, store); !reflect.DeepEqual(state, expect) {
+		t.Logf("expect %v but got %v", expect, state)
+		t.FailNow()
+	}
+	if evt, _ := store.Get(0); evt.Metadata["room"] != "A" {
+		t.Logf("metadata lost %v", evt)
+		t.FailNow()
+	}
+	// ids are not reused after a restart
+	created, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, 100, 200}})
+	if err != nil || created.Id != 7 {
+		t.Logf("expect id 7 but got %d %v", created.Id, err)
+		t.FailNow()
+	}
+	store.Close()
+}
+
+// a weekly meeting in a zone only known by VTIMEZONE keeps its DST rules after a restart,
+// from the log and from a snapshot
+func TestFileStore_VTimezone(t *testing.T) {
+	input := strings.Replace(testICS, "UID:summer@test", "UID:summer@test\nRRULE:FREQ=WEEKLY;COUNT=3", 1)
+	input = strings.Replace(input, "20260701T", "20261019T", 2)
+	evts, err := ParseICS(strings.NewReader(input))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	// -0400 until November 1st, -0500 after it
+	expect := []string{"10-19 13:00", "10-26 13:00", "11-02 14:00"}
+	for _, opts := range []FileStoreOptions{{}, {SnapshotEvery: 1}} {
+		dir := t.TempDir()
+		store := openFileStore(t, dir, opts)
+		if _, _, err := store.Create(evts[1]); err != nil {
+			t.Logf("create failed: %s", err)
+			t.FailNow()
+		}
+		store.Close()
+
+		store = openFileStore(t, dir, opts)
+		meeting, err := store.Get(0)
+		store.Close()
+		if err != nil {
+			t.Logf("get failed: %s", err)
+			t.FailNow()
+		}
+		var got []string
+		for _, occurrence := range meeting.Occurrences(meeting.Start, meeting.Start+30*24*3600, time.UTC) {
+			got = append(got, time.Unix(occurrence.Start, 0).UTC().Format("01-02 15:04"))
+		}
+		if !sameStrings(got, expect) {
+			t.Logf("snapshot every %d: expect occurrences %v but got %v", opts.SnapshotEvery, expect, got)
+			t.FailNow()
+		}
+	}
+}
+
+func TestFileStore_SyncInterval(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir, FileStoreOptions{Sync: SyncInterval, SyncInterval: time.Millisecond})
+	for i := 0; i < 20; i++ {
+		if _, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, int64(i * 10), int64(i*10 + 5)}}); err != nil {
+			t.Logf("create failed: %s", err)
+			t.FailNow()
+		}
+		time.Sleep(time.Millisecond)
+	}
+	store.Close()
+	store = openFileStore(t, dir, FileStoreOptions{})
+	if store.Len() != 20 {
+		t.Logf("expect 20 events but got %d", store.Len())
+		t.FailNow()
+	}
+	store.Close()
+}
+
+// cut the log at every byte as a crash in the middle of a write would, the store should come
+// back with every write finished before the cut and nothing after it
+func TestFileStore_TornLog(t *testing.T) {
+	dir := t.TempDir()
+	opts := FileStoreOptions{Sync: SyncNever, SnapshotEvery: -1}
+	store := openFileStore(t, dir, opts)
+	var sizes []int64
+	var states []map[int]string
+	record := func() {
+		info, _ := os.Stat(filepath.Join(dir, walFile))
+		sizes = append(sizes, info.Size())
+		states = append(states, storeState(t, store))
+	}
+	record()
+	for i := 0; i < 4; i++ {
+		evt := Event{CalendarEvent: CalendarEvent{0, int64(i * 100), int64(i*100 + 50)}, Title: fmt.Sprintf("Event %d", i)}
+		store.Create(evt)
+		record()
+	}
+	evt, _ := store.Get(1)
+	evt.Title = "Changed"
+	store.Update(evt)
+	record()
+	store.Delete(2, 0)
+	record()
+	store.Close()
+	data, _ := os.ReadFile(filepath.Join(dir, walFile))
+
+	crash := t.TempDir()
+	for cut := 0; cut <= len(data); cut++ {
+		os.WriteFile(filepath.Join(crash, walFile), data[:cut], 0644)
+		expect := 0
+		for i, size := range sizes {
+			if size <= int64(cut) {
+				expect = i
+			}
+		}
+		store := openFileStore(t, crash, opts)
+		if state := storeState(t, store); !reflect.DeepEqual(state, states[expect]) {
+			t.Logf("cut at %d: expect %v but got %v", cut, states[expect], state)
+			t.FailNow()
+		}
+		// the torn record is gone, so new writes are read back fine
+		created, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, 1000, 1100}, Title: "After"})
+		if err != nil {
+			t.Logf("cut at %d: create failed: %s", cut, err)
+			t.FailNow()
+		}
+		store.Close()
+		store = openFileStore(t, crash, opts)
+		if evt, err := store.Get(created.Id); err != nil || evt.Title != "After" || store.Len() != len(states[expect])+1 {
+			t.Logf("cut at %d: write after recovery lost %v", cut, err)
+			t.FailNow()
+		}
+		store.Close()
+	}
+}
+
+func TestFileStore_CrashDuringSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := FileStoreOptions{SnapshotEvery: -1}
+	store := openFileStore(t, dir, opts)
+	for i := 0; i < 5; i++ {
+		store.Create(Event{CalendarEvent: CalendarEvent{0, int64(i * 100), int64(i*100 + 50)}})
+	}
+	store.Delete(4, 0)
+	expect := storeState(t, store)
+	log, _ := os.ReadFile(filepath.Join(dir, walFile))
+
+	// crashed while writing the snapshot
+	os.WriteFile(filepath.Join(dir, snapshotTmpFile), []byte(`{"seq":6,"nextId":5,"ev`), 0644)
+	store.Close()
+	store = openFileStore(t, dir, opts)
+	if state := storeState(t, store); !reflect.DeepEqual(state, expect) {
+		t.Logf("expect %v but got %v", expect, state)
+		t.FailNow()
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotTmpFile)); !os.IsNotExist(err) {
+		t.Logf("unfinished snapshot should be removed")
+		t.FailNow()
+	}
+
+	// crashed after the snapshot before the log is cut, records in both are applied once
+	if err := store.Snapshot(); err != nil {
+		t.Logf("snapshot failed: %s", err)
+		t.FailNow()
+	}
+	store.Close()
+	os.WriteFile(filepath.Join(dir, walFile), log, 0644)
+	store = openFileStore(t, dir, opts)
+	if state := storeState(t, store); !reflect.DeepEqual(state, expect) {
+		t.Logf("expect %v but got %v", expect, state)
+		t.FailNow()
+	}
+	created, _, _ := store.Create(Event{CalendarEvent: CalendarEvent{0, 1000, 1100}})
+	store.Close()
+	store = openFileStore(t, dir, opts)
+	if created.Id != 5 || store.Len() != 5 {
+		t.Logf("expect id 5 and 5 events but got %d and %d", created.Id, store.Len())
+		t.FailNow()
+	}
+	store.Close()
+}
+
+func TestFileStore_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir, FileStoreOptions{})
+	for i := 0; i < 3; i++ {
+		store.Create(Event{CalendarEvent: CalendarEvent{0, int64(i * 100), int64(i*100 + 50)}})
+	}
+	store.Close()
+	name := filepath.Join(dir, walFile)
+	data, _ := os.ReadFile(name)
+	lines := strings.SplitAfter(string(data), "\n")
+	lines[1] = strings.Replace(lines[1], `"id":1`, `"id":7`, 1)
+	os.WriteFile(name, []byte(strings.Join(lines, "")), 0644)
+	_, err := OpenFileStore(dir, FileStoreOptions{})
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.Line != 2 || errors.Cause(parseErr.Err) != errorCorruptLog {
+		t.Logf("expect corrupt log on line 2 but got %v", err)
+		t.FailNow()
+	}
+}
+
+// run by TestFileStore_Kill in another process, prints the id of every finished write
+func TestFileStoreChild(t *testing.T) {
+	dir := os.Getenv("CALENDAR_FILESTORE_DIR")
+	if dir == "" {
+		t.Skip("only run by TestFileStore_Kill")
+	}
+	store := openFileStore(t, dir, FileStoreOptions{SnapshotEvery: 20})
+	for i := 0; ; i++ {
+		created, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, int64(i * 10), int64(i*10 + 5)}, Title: "Event"})
+		if err != nil {
+			t.Logf("create failed: %s", err)
+			t.FailNow()
+		}
+		fmt.Printf("created %d\n", created.Id)
+	}
+}
+
+// kill -9 a process writing to the store at random points, every write it finished should be there
+func TestFileStore_Kill(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts other processes")
+	}
+	dir := t.TempDir()
+	acked := make(map[int]bool)
+	for round := 0; round < 5; round++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFileStoreChild$")
+		cmd.Env = append(os.Environ(), "CALENDAR_FILESTORE_DIR="+dir)
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Logf("pipe failed: %s", err)
+			t.FailNow()
+		}
+		if err := cmd.Start(); err != nil {
+			t.Logf("start failed: %s", err)
+			t.FailNow()
+		}
+		scanner := bufio.NewScanner(out)
+		for count := 0; count < 30+round*17 && scanner.Scan(); {
+			if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "created" {
+				id, _ := strconv.Atoi(fields[1])
+				acked[id] = true
+				count++
+			}
+		}
+		cmd.Process.Kill()
+		// writes finished while we were not looking count too
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "created" {
+				id, _ := strconv.Atoi(fields[1])
+				acked[id] = true
+			}
+		}
+		cmd.Wait()
+
+		store := openFileStore(t, dir, FileStoreOptions{})
+		for id := range acked {
+			if evt, err := store.Get(id); err != nil || evt.Title != "Event" {
+				t.Logf("round %d: finished write %d is lost: %v", round, id, err)
+				t.FailNow()
+			}
+		}
+		// at most the write in flight made it without being reported
+		if store.Len() != len(acked) && store.Len() != len(acked)+1 {
+			t.Logf("round %d: expect %d events but got %d", round, len(acked), store.Len())
+			t.FailNow()
+		}
+		// the write in flight is durable now, so the next round allows one more of its own
+		for id := range storeState(t, store) {
+			acked[id] = true
+		}
+		store.Close()
+	}
+}
@@ -0,0 +1,231 @@
+package calendar
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// OverlapFinder is a strategy of finding all overlap pairs
+// implementations must return every pair once with FirstId < SecondId and never change evts
+type OverlapFinder interface {
+	Name() string
+	FindOverlapPairs(evts CalendarEvents) []CalendarPair
+}
+
+type BrutalFinder struct{}
+
+func (c BrutalFinder) Name() string {
+	return AlgorithmBrutal.String()
+}
+
+func (c BrutalFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	return FindOverlapPairsBrutal(evts)
+}
+
+type SortFinder struct{}
+
+func (c SortFinder) Name() string {
+	return AlgorithmSort.String()
+}
+
+func (c SortFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	return FindOverlapPairsSort(evts)
+}
+
+type SegFinder struct{}
+
+func (c SegFinder) Name() string {
+	return AlgorithmSeg.String()
+}
+
+func (c SegFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	return FindOverlapPairsSeg(evts)
+}
+
+type BucketFinder struct {
+	// <= 0 means picking by ChooseBucketSize
+	Size int64
+}
+
+func (c BucketFinder) Name() string {
+	return AlgorithmBucket.String()
+}
+
+func (c BucketFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	size := c.Size
+	if size <= 0 {
+		size = ChooseBucketSize(evts)
+	}
+	// the size is positive, so there is no error
+	ret, _ := FindOverlapPairBucket(evts, size)
+	return ret
+}
+
+type SweepFinder struct{}
+
+func (c SweepFinder) Name() string {
+	return AlgorithmSweep.String()
+}
+
+func (c SweepFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	return FindOverlapPairsSweep(evts)
+}
+
+type ParallelFinder struct {
+	// <= 0 means using all cores
+	Workers int
+}
+
+func (c ParallelFinder) Name() string {
+	return AlgorithmParallel.String()
+}
+
+func (c ParallelFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	return FindOverlapPairsParallel(evts, c.Workers)
+}
+
+// thresholds used by AutoFinder
+const (
+	autoSmallSize    = 64
+	autoParallelSize = 50000
+)
+
+// AutoFinder picks the strategy by input size, time span and density
+type AutoFinder struct {
+	// called with the chosen finder and the reason, useful for logging
+	OnChoose func(finder OverlapFinder, reason string)
+}
+
+func (c AutoFinder) Name() string {
+	return AlgorithmAuto.String()
+}
+
+func (c AutoFinder) FindOverlapPairs(evts CalendarEvents) []CalendarPair {
+	finder, reason := c.Choose(evts)
+	if c.OnChoose != nil {
+		c.OnChoose(finder, reason)
+	}
+	return finder.FindOverlapPairs(evts)
+}
+
+// return the finder going to be used for evts and why
+func (c AutoFinder) Choose(evts CalendarEvents) (OverlapFinder, string) {
+	length := len(evts)
+	if length < autoSmallSize {
+		return BrutalFinder{}, "small input"
+	}
+
+	minStart, maxEnd := evts[0].Start, evts[0].End
+	var total, longest int64
+	for i := range evts {
+		if evts[i].Start < minStart {
+			minStart = evts[i].Start
+		}
+		if evts[i].End > maxEnd {
+			maxEnd = evts[i].End
+		}
+		duration := evts[i].End - evts[i].Start + 1
+		total += duration
+		if duration > longest {
+			longest = duration
+		}
+	}
+	span := maxEnd - minStart + 1
+	// average number of events at the same time, the output is close to n^2 when it is high
+	density := float64(total) / float64(span)
+	if density*4 >= float64(length) {
+		return BrutalFinder{}, "dense input"
+	}
+	if length >= autoParallelSize && runtime.NumCPU() > 1 {
+		return ParallelFinder{}, "large input"
+	}
+	// buckets work well when no event is much longer than the others
+	size := ChooseBucketSize(evts)
+	if longest <= size*4 && span/size <= int64(length)*4 {
+		return BucketFinder{Size: size}, "uniform event length"
+	}
+	return SweepFinder{}, "default"
+}
+
+var errorDuplicatedFinder = errors.New("finder already registered")
+
+var finderRegistry = struct {
+	sync.RWMutex
+	finders map[string]OverlapFinder
+}{finders: make(map[string]OverlapFinder)}
+
+func init() {
+	for _, finder := range []OverlapFinder{
+		BrutalFinder{}, SortFinder{}, SegFinder{}, BucketFinder{}, SweepFinder{}, ParallelFinder{}, AutoFinder{},
+	} {
+		if err := RegisterFinder(finder); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// register a finder by its name, so it can be looked up and checked like the built in ones
+func RegisterFinder(finder OverlapFinder) error {
+	finderRegistry.Lock()
+	defer finderRegistry.Unlock()
+	if _, ok := finderRegistry.finders[finder.Name()]; ok {
+		return errors.Wrapf(errorDuplicatedFinder, "%q", finder.Name())
+	}
+	finderRegistry.finders[finder.Name()] = finder
+	return nil
+}
+
+func LookupFinder(name string) (OverlapFinder, bool) {
+	finderRegistry.RLock()
+	defer finderRegistry.RUnlock()
+	finder, ok := finderRegistry.finders[name]
+	return finder, ok
+}
+
+// names of all registered finders in alphabet order
+func FinderNames() (ret []string) {
+	finderRegistry.RLock()
+	defer finderRegistry.RUnlock()
+	for name := range finderRegistry.finders {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return
+}
+
+// conformance check of a finder against FindOverlapPairsBrutal on evts
+// it also makes sure pairs are normalized, not duplicated and the input is untouched
+func CheckFinder(finder OverlapFinder, evts CalendarEvents) error {
+	original := make(CalendarEvents, len(evts))
+	copy(original, evts)
+
+	ret := finder.FindOverlapPairs(evts)
+	for i := range evts {
+		if evts[i] != original[i] {
+			return errors.Errorf("%s changed the input events", finder.Name())
+		}
+	}
+	baseline := FindOverlapPairsBrutal(evts)
+	if len(ret) != len(baseline) {
+		return errors.Errorf("%s found %d pairs instead of %d", finder.Name(), len(ret), len(baseline))
+	}
+	found := make(map[CalendarPair]bool, len(ret))
+	for _, pair := range ret {
+		if pair.FirstId >= pair.SecondId {
+			return errors.Errorf("%s pair %v is not normalized", finder.Name(), pair)
+		}
+		if found[pair] {
+			return errors.Errorf("%s pair %v is duplicated", finder.Name(), pair)
+		}
+		found[pair] = true
+	}
+	for _, pair := range baseline {
+		if !found[pair] {
+			return errors.Errorf("%s missed pair %v", finder.Name(), pair)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package calendar
+
+import (
+	"math/rand"
+	"testing"
+)
+
+type reversedFinder struct{}
+
+func (c reversedFinder) Name() string {
+	return "reversed"
+}
+
+func (c reversedFinder) FindOverlapPairs(evts CalendarEvents) (ret []CalendarPair) {
+	for i := len(evts) - 1; i >= 0; i-- {
+		for p := i - 1; p >= 0; p-- {
+			if evts[i].isOverlap(evts[p]) {
+				ret = append(ret, newCalendarPair(evts[i].Id, evts[p].Id))
+			}
+		}
+	}
+	return
+}
+
+func conformanceFixtures() map[string]CalendarEvents {
+	r := rand.New(rand.NewSource(3))
+	long := make(CalendarEvents, 300)
+	for i := range long {
+		start := r.Int63n(100000)
+		long[i] = CalendarEvent{i, start, start + r.Int63n(50)}
+	}
+	// a few events spanning almost everything
+	long[10].End = 100000
+	long[20].Start = 0
+
+	dense := make(CalendarEvents, 100)
+	for i := range dense {
+		dense[i] = CalendarEvent{i, r.Int63n(10), 10 + r.Int63n(10)}
+	}
+	return map[string]CalendarEvents{
+		"empty":    nil,
+		"single":   {{0, 0, 100}},
+		"touching": {{0, 0, 100}, {1, 100, 200}, {2, 201, 300}},
+		"nested":   {{3, 0, 1000}, {1, 10, 20}, {2, 15, 15}, {0, 500, 1000}},
+		"random":   testEvents,
+		"long":     long,
+		"dense":    dense,
+	}
+}
+
+func TestFinderConformance(t *testing.T) {
+	if _, ok := LookupFinder("reversed"); !ok {
+		if err := RegisterFinder(reversedFinder{}); err != nil {
+			t.Logf("register failed: %s", err)
+			t.FailNow()
+		}
+	}
+	if err := RegisterFinder(reversedFinder{}); err == nil {
+		t.Log("register same name twice should fail")
+		t.FailNow()
+	}
+
+	for _, name := range FinderNames() {
+		finder, ok := LookupFinder(name)
+		if !ok {
+			t.Logf("finder %s should be registered", name)
+			t.FailNow()
+		}
+		for fixture, events := range conformanceFixtures() {
+			if err := CheckFinder(finder, events); err != nil {
+				t.Logf("%s: %s", fixture, err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestAutoFinder_Choose(t *testing.T) {
+	fixtures := conformanceFixtures()
+	cases := map[string]string{
+		"nested": "brutal",
+		"dense":  "brutal",
+		"long":   "sweep",
+	}
+	for fixture, expected := range cases {
+		if finder, reason := (AutoFinder{}).Choose(fixtures[fixture]); finder.Name() != expected {
+			t.Logf("%s should choose %s instead of %s because %s", fixture, expected, finder.Name(), reason)
+			t.FailNow()
+		}
+	}
+
+	uniform := make(CalendarEvents, 1000)
+	for i := range uniform {
+		uniform[i] = CalendarEvent{i, int64(i * 100), int64(i*100 + 30)}
+	}
+	var chosen string
+	finder := AutoFinder{OnChoose: func(finder OverlapFinder, reason string) {
+		chosen = finder.Name()
+	}}
+	finder.FindOverlapPairs(uniform)
+	if chosen != "bucket" {
+		t.Logf("uniform events should choose bucket instead of %s", chosen)
+		t.FailNow()
+	}
+}
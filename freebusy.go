@@ -0,0 +1,127 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// closed time range [Start, End] in unix timestamp, same as CalendarEvent without id
+type Interval struct {
+	Start int64
+	End   int64
+}
+
+// e.g. 2026-10-18 09:00 UTC -> 09:15 UTC
+func (c *Interval) ToString() string {
+	return formatRange(c.Start, c.End, time.UTC)
+}
+
+// length in seconds, both ends included
+func (c *Interval) Len() int64 {
+	return c.End - c.Start + 1
+}
+
+// FreeBusy merges the events within [windowStart, windowEnd] into busy blocks and returns
+// the gaps between them as free, both are in order and clipped to the window
+// events touching each other, e.g. [0, 9] and [10, 19], are one block since seconds are
+// closed, the blocks are the same as merging adjacent Segments of FindSegments
+func FreeBusy(evts CalendarEvents, windowStart, windowEnd int64) (busy, free []Interval) {
+	return FreeBusyMode(evts, windowStart, windowEnd, BoundaryClosed)
+}
+
+// same as FreeBusy for events with the boundary mode, intervals returned are always closed
+func FreeBusyMode(evts CalendarEvents, windowStart, windowEnd int64, mode BoundaryMode) (busy, free []Interval) {
+	if windowEnd < windowStart {
+		return
+	}
+	clipped := make([]Interval, 0, len(evts))
+	for _, evt := range evts {
+		closed, ok := mode.Closed(evt)
+		if !ok || closed.End < windowStart || closed.Start > windowEnd {
+			continue
+		}
+		if closed.Start < windowStart {
+			closed.Start = windowStart
+		}
+		if closed.End > windowEnd {
+			closed.End = windowEnd
+		}
+		clipped = append(clipped, Interval{closed.Start, closed.End})
+	}
+	busy = mergeIntervals(clipped)
+
+	next := windowStart
+	for _, block := range busy {
+		if block.Start > next {
+			free = append(free, Interval{next, block.Start - 1})
+		}
+		next = block.End + 1
+	}
+	if next <= windowEnd {
+		free = append(free, Interval{next, windowEnd})
+	}
+	return
+}
+
+// sort and merge overlapping and touching intervals, intervals is sorted in place
+func mergeIntervals(intervals []Interval) (ret []Interval) {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start < intervals[j].Start
+	})
+	for _, interval := range intervals {
+		if last := len(ret) - 1; last >= 0 && interval.Start <= ret[last].End+1 {
+			if interval.End > ret[last].End {
+				ret[last].End = interval.End
+			}
+			continue
+		}
+		ret = append(ret, interval)
+	}
+	return
+}
+
+// WriteFreeBusy writes a VCALENDAR with one VFREEBUSY of attendee for the window
+// FREEBUSY periods end right after the busy block as periods of RFC 5545 exclude the end
+func WriteFreeBusy(w io.Writer, attendee Attendee, windowStart, windowEnd int64, busy []Interval, opts ICSOptions) error {
+	out := &icsWriter{w: bufio.NewWriter(w)}
+	stamp := opts.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	prodId := opts.ProdId
+	if prodId == "" {
+		prodId = defaultProdId
+	}
+	utc := func(t int64) string {
+		return time.Unix(t, 0).UTC().Format("20060102T150405Z")
+	}
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", escapeICSText(prodId))
+	out.line("METHOD", "PUBLISH")
+	out.line("BEGIN", "VFREEBUSY")
+	out.line("UID", fmt.Sprintf("freebusy-%d-%d@calendar-test", windowStart, windowEnd))
+	out.line("DTSTAMP", utc(stamp.Unix()))
+	out.line("DTSTART", utc(windowStart))
+	out.line("DTEND", utc(windowEnd+1))
+	if attendee.Email != "" {
+		out.attendee("ORGANIZER", &attendee, false)
+	}
+	if len(busy) > 0 {
+		periods := make([]string, len(busy))
+		for i, block := range busy {
+			periods[i] = utc(block.Start) + "/" + utc(block.End+1)
+		}
+		out.line("FREEBUSY", strings.Join(periods, ","), "FBTYPE=BUSY")
+	}
+	out.line("END", "VFREEBUSY")
+	out.line("END", "VCALENDAR")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
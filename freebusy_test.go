@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreeBusy(t *testing.T) {
+	evts := CalendarEvents{{0, 10, 20}, {1, 15, 30}, {2, 31, 40}, {3, 50, 60}, {4, 55, 58}, {5, 90, 120}, {6, 0, 5}}
+	busy, free := FreeBusy(evts, 8, 100)
+	expectBusy := []Interval{{10, 40}, {50, 60}, {90, 100}}
+	expectFree := []Interval{{8, 9}, {41, 49}, {61, 89}}
+	if !reflect.DeepEqual(busy, expectBusy) || !reflect.DeepEqual(free, expectFree) {
+		t.Logf("wrong busy %v and free %v", busy, free)
+		t.FailNow()
+	}
+
+	// back to back events are not one block when the end is excluded
+	busy, free = FreeBusyMode(CalendarEvents{{0, 10, 20}, {1, 20, 30}, {2, 40, 40}}, 0, 50, BoundaryHalfOpen)
+	if !reflect.DeepEqual(busy, []Interval{{10, 29}}) || !reflect.DeepEqual(free, []Interval{{0, 9}, {30, 50}}) {
+		t.Logf("wrong busy %v and free %v", busy, free)
+		t.FailNow()
+	}
+
+	busy, free = FreeBusy(nil, 0, 9)
+	if busy != nil || !reflect.DeepEqual(free, []Interval{{0, 9}}) {
+		t.Logf("wrong busy %v and free %v", busy, free)
+		t.FailNow()
+	}
+	busy, free = FreeBusy(evts, 10, 5)
+	if busy != nil || free != nil {
+		t.Logf("empty window should be nothing")
+		t.FailNow()
+	}
+
+	// same blocks as the segments, and busy and free cover the window without overlap
+	busy, free = FreeBusy(testEvents, 0, 1<<40)
+	segs, _ := FindSegments(testEvents)
+	var merged []Interval
+	for _, seg := range segs {
+		merged = append(merged, Interval{seg.Start, seg.End})
+	}
+	if !reflect.DeepEqual(busy, mergeIntervals(merged)) {
+		t.Logf("busy blocks differ from segments")
+		t.FailNow()
+	}
+	var total int64
+	for _, interval := range append(busy, free...) {
+		total += interval.Len()
+	}
+	if total != 1<<40+1 {
+		t.Logf("busy and free should cover the window but got %d seconds", total)
+		t.FailNow()
+	}
+}
+
+func TestWriteFreeBusy(t *testing.T) {
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC).Unix()
+	busy := []Interval{{start + 9*3600, start + 10*3600 - 1}, {start + 13*3600, start + 14*3600 - 1}}
+	var b bytes.Buffer
+	err := WriteFreeBusy(&b, Attendee{Name: "Ann", Email: "ann@example.com"}, start, start+24*3600-1, busy,
+		ICSOptions{Stamp: time.Unix(start, 0)})
+	if err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	for _, expect := range []string{
+		"BEGIN:VFREEBUSY\r\n",
+		"DTSTART:20261018T000000Z\r\n",
+		"DTEND:20261019T000000Z\r\n",
+		"ORGANIZER;CN=Ann:mailto:ann@example.com\r\n",
+		"FREEBUSY;FBTYPE=BUSY:20261018T090000Z/20261018T100000Z,20261018T130000Z/202\r\n 61018T140000Z\r\n",
+	} {
+		if !strings.Contains(b.String(), expect) {
+			t.Logf("missing %q in\n%s", expect, b.String())
+			t.FailNow()
+		}
+	}
+	// still a valid calendar for our parser
+	if _, err := parseICSComponents(bytes.NewReader(b.Bytes())); err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+}
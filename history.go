@@ -0,0 +1,306 @@
+package calendar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ChangeKind int
+
+const (
+	ChangeCreate ChangeKind = iota
+	ChangeUpdate
+	ChangeDelete
+	// a deleted event created again under a new id by Undo
+	ChangeRestore
+)
+
+func (c ChangeKind) String() string {
+	switch c {
+	case ChangeCreate:
+		return "create"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	case ChangeRestore:
+		return "restore"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(c))
+}
+
+func (c ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// one write to an event
+type Change struct {
+	// order of the change among all changes, from 1
+	Seq     int64      `json:"seq"`
+	EventId int        `json:"eventId"`
+	Kind    ChangeKind `json:"kind"`
+	Actor   string     `json:"actor"`
+	Time    time.Time  `json:"time"`
+	// nil for create, the deleted event with its old id for restore
+	Before *Event `json:"before"`
+	// nil for delete
+	After *Event `json:"after"`
+}
+
+type HistoryOptions struct {
+	// time of the changes, time.Now by default
+	Clock func() time.Time
+}
+
+var errorNoHistory = errors.New("no history")
+var errorRestored = errors.New("event already restored")
+
+// HistoryStore records every write made through it, writes are made as an actor by As
+// writes made to the wrapped store directly are not recorded
+// the changes are kept in memory only, they are lost on restart even over a FileStore
+type HistoryStore struct {
+	// serializes writes, so changes are recorded in the order they are made
+	mu      sync.Mutex
+	store   Store
+	clock   func() time.Time
+	changes []Change
+	// event id to index of its changes
+	byEvent map[int][]int
+}
+
+func NewHistoryStore(store Store, opts HistoryOptions) *HistoryStore {
+	clock := opts.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+	return &HistoryStore{store: store, clock: clock, byEvent: make(map[int][]int)}
+}
+
+// As returns the store writing as actor
+func (c *HistoryStore) As(actor string) Store {
+	return &actorStore{c, actor}
+}
+
+// History returns the changes of the event, oldest first
+func (c *HistoryStore) History(id int) []Change {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make([]Change, 0, len(c.byEvent[id]))
+	for _, i := range c.byEvent[id] {
+		ret = append(ret, cloneChange(c.changes[i]))
+	}
+	return ret
+}
+
+// Changes returns the changes made within [from, to] by any actor, oldest first
+// zero from or to means no limit
+func (c *HistoryStore) Changes(from, to time.Time) (ret []Change) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, change := range c.changes {
+		if !from.IsZero() && change.Time.Before(from) || !to.IsZero() && change.Time.After(to) {
+			continue
+		}
+		ret = append(ret, cloneChange(change))
+	}
+	return
+}
+
+// Revert puts the event back to how it was at version, as a new update made by actor
+// the restored times are checked against the other events like any update, so it fails
+// with ErrOverlap when the store rejects conflicts and the old time is taken by now
+func (c *HistoryStore) Revert(actor string, id int, version int64) (Event, []int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var target *Event
+	for _, i := range c.byEvent[id] {
+		// a restore of the event under a new id is in its history too
+		if after := c.changes[i].After; after != nil && after.Id == id && after.Version == version {
+			target = after
+		}
+	}
+	if target == nil {
+		return Event{}, nil, errors.Wrapf(errorNoHistory, "event %d at version %d", id, version)
+	}
+	current, err := c.store.Get(id)
+	if err != nil {
+		return Event{}, nil, err
+	}
+	evt := cloneEvent(*target)
+	// only if nobody changed it since we looked
+	evt.Version = current.Version
+	return c.update(actor, evt)
+}
+
+// Undo reverts the last change of the event made by anyone
+// a created event is deleted, a deleted one is created again under a new id and the restore
+// is in the history of both ids, undoing the delete again fails as the event is back already
+func (c *HistoryStore) Undo(actor string, id int) (Event, []int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changes := c.byEvent[id]
+	if len(changes) == 0 {
+		return Event{}, nil, errors.Wrapf(errorNoHistory, "event %d", id)
+	}
+	last := c.changes[changes[len(changes)-1]]
+	switch last.Kind {
+	case ChangeCreate:
+		return Event{}, nil, c.delete(actor, id, last.After.Version)
+	case ChangeUpdate:
+		evt := cloneEvent(*last.Before)
+		evt.Version = last.After.Version
+		return c.update(actor, evt)
+	case ChangeRestore:
+		if last.After.Id != id {
+			return Event{}, nil, errors.Wrapf(errorRestored, "event %d as %d", id, last.After.Id)
+		}
+		// the restored event goes away like a created one
+		return Event{}, nil, c.delete(actor, id, last.After.Version)
+	}
+	created, conflicts, err := c.store.Create(cloneEvent(*last.Before))
+	if err != nil {
+		return created, conflicts, err
+	}
+	c.record(actor, ChangeRestore, last.Before, &created)
+	return created, conflicts, nil
+}
+
+// the lock is held by the callers of create, update and delete
+func (c *HistoryStore) create(actor string, evt Event) (Event, []int, error) {
+	created, conflicts, err := c.store.Create(evt)
+	if err != nil {
+		return created, conflicts, err
+	}
+	c.record(actor, ChangeCreate, nil, &created)
+	return created, conflicts, nil
+}
+
+func (c *HistoryStore) update(actor string, evt Event) (Event, []int, error) {
+	before, err := c.store.Get(evt.Id)
+	if err != nil {
+		return Event{}, nil, err
+	}
+	updated, conflicts, err := c.store.Update(evt)
+	if err != nil {
+		return updated, conflicts, err
+	}
+	c.record(actor, ChangeUpdate, &before, &updated)
+	return updated, conflicts, nil
+}
+
+func (c *HistoryStore) delete(actor string, id int, version int64) error {
+	before, err := c.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := c.store.Delete(id, version); err != nil {
+		return err
+	}
+	c.record(actor, ChangeDelete, &before, nil)
+	return nil
+}
+
+func (c *HistoryStore) batch(actor string, ops []BatchOp) ([]BatchResult, error) {
+	// the state before the batch, ops on the same event are recorded one after another
+	current := make(map[int]*Event)
+	for _, op := range ops {
+		if op.Kind == BatchCreate {
+			continue
+		}
+		if evt, err := c.store.Get(op.Event.Id); err == nil {
+			current[op.Event.Id] = &evt
+		}
+	}
+	results, err := c.store.Batch(ops)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		after := results[i].Event
+		switch op.Kind {
+		case BatchCreate:
+			c.record(actor, ChangeCreate, nil, &after)
+			current[after.Id] = &after
+		case BatchUpdate:
+			c.record(actor, ChangeUpdate, current[after.Id], &after)
+			current[after.Id] = &after
+		case BatchDelete:
+			c.record(actor, ChangeDelete, current[op.Event.Id], nil)
+			delete(current, op.Event.Id)
+		}
+	}
+	return results, nil
+}
+
+func (c *HistoryStore) record(actor string, kind ChangeKind, before, after *Event) {
+	change := Change{Seq: int64(len(c.changes) + 1), Kind: kind, Actor: actor, Time: c.clock()}
+	if before != nil {
+		evt := cloneEvent(*before)
+		change.Before = &evt
+		change.EventId = evt.Id
+	}
+	if after != nil {
+		evt := cloneEvent(*after)
+		change.After = &evt
+		change.EventId = evt.Id
+	}
+	c.byEvent[change.EventId] = append(c.byEvent[change.EventId], len(c.changes))
+	if kind == ChangeRestore {
+		// so the old id tells where the event went
+		c.byEvent[before.Id] = append(c.byEvent[before.Id], len(c.changes))
+	}
+	c.changes = append(c.changes, change)
+}
+
+func cloneChange(change Change) Change {
+	if change.Before != nil {
+		evt := cloneEvent(*change.Before)
+		change.Before = &evt
+	}
+	if change.After != nil {
+		evt := cloneEvent(*change.After)
+		change.After = &evt
+	}
+	return change
+}
+
+// Store of HistoryStore.As, reads go to the wrapped store
+type actorStore struct {
+	history *HistoryStore
+	actor   string
+}
+
+func (c *actorStore) Create(evt Event) (Event, []int, error) {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	return c.history.create(c.actor, evt)
+}
+
+func (c *actorStore) Get(id int) (Event, error) {
+	return c.history.store.Get(id)
+}
+
+func (c *actorStore) Update(evt Event) (Event, []int, error) {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	return c.history.update(c.actor, evt)
+}
+
+func (c *actorStore) Delete(id int, version int64) error {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	return c.history.delete(c.actor, id, version)
+}
+
+func (c *actorStore) List(start, end int64) (Events, error) {
+	return c.history.store.List(start, end)
+}
+
+func (c *actorStore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	return c.history.batch(c.actor, ops)
+}
@@ -0,0 +1,185 @@
+package calendar
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestHistoryStore(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	history := NewHistoryStore(NewMemoryStore(StoreOptions{Conflicts: ConflictReject}), HistoryOptions{Clock: clock})
+	ann, bob := history.As("ann"), history.As("bob")
+
+	meeting, _, _ := ann.Create(Event{CalendarEvent: CalendarEvent{0, 1000, 2000}, Title: "Planning"})
+	moved := meeting
+	moved.Start, moved.End = 5000, 6000
+	moved, _, err := bob.Update(moved)
+	if err != nil {
+		t.Logf("update failed: %s", err)
+		t.FailNow()
+	}
+
+	// who moved my meeting?
+	changes := history.History(meeting.Id)
+	if len(changes) != 2 || changes[0].Kind != ChangeCreate || changes[0].Actor != "ann" || changes[0].Before != nil {
+		t.Logf("wrong history %v", changes)
+		t.FailNow()
+	}
+	if changes[1].Actor != "bob" || changes[1].Before.Start != 1000 || changes[1].After.Start != 5000 || changes[1].Seq != 2 {
+		t.Logf("wrong change %v", changes[1])
+		t.FailNow()
+	}
+	if !changes[1].Time.Equal(time.Date(2026, 10, 18, 9, 2, 0, 0, time.UTC)) {
+		t.Logf("wrong time %s", changes[1].Time)
+		t.FailNow()
+	}
+	// history is a copy
+	changes[1].After.Title = "Changed"
+	if history.History(meeting.Id)[1].After.Title != "Planning" {
+		t.Logf("history changed by the caller")
+		t.FailNow()
+	}
+	data, _ := json.Marshal(changes[1])
+	if !strings.Contains(string(data), `"kind":"update","actor":"bob"`) {
+		t.Logf("wrong JSON %s", data)
+		t.FailNow()
+	}
+
+	// the old time is taken by now, so reverting fails
+	other, _, _ := ann.Create(Event{CalendarEvent: CalendarEvent{0, 1500, 1600}, Title: "Lunch"})
+	if _, _, err := history.Revert("carol", meeting.Id, 1); errors.Cause(err) != ErrOverlap {
+		t.Logf("expect overlap but got %v", err)
+		t.FailNow()
+	}
+	if err := ann.Delete(other.Id, other.Version); err != nil {
+		t.Logf("delete failed: %s", err)
+		t.FailNow()
+	}
+	reverted, _, err := history.Revert("carol", meeting.Id, 1)
+	if err != nil || reverted.Start != 1000 || reverted.Version != 3 {
+		t.Logf("revert failed: %v %v", reverted, err)
+		t.FailNow()
+	}
+	if changes := history.History(meeting.Id); len(changes) != 3 || changes[2].Actor != "carol" || changes[2].Before.Start != 5000 {
+		t.Logf("revert should be recorded %v", changes)
+		t.FailNow()
+	}
+	if _, _, err := history.Revert("carol", meeting.Id, 9); errors.Cause(err) != errorNoHistory {
+		t.Logf("expect no history but got %v", err)
+		t.FailNow()
+	}
+
+	// undo the revert, the delete of lunch and then the lunch created again
+	undone, _, err := history.Undo("ann", meeting.Id)
+	if err != nil || undone.Start != 5000 || undone.Version != 4 {
+		t.Logf("undo failed: %v %v", undone, err)
+		t.FailNow()
+	}
+	if _, _, err := history.Undo("ann", other.Id); err != nil {
+		t.Logf("undo delete failed: %s", err)
+		t.FailNow()
+	}
+	// undoing the delete twice does not bring back two lunches
+	if _, _, err := history.Undo("bob", other.Id); errors.Cause(err) != errorRestored {
+		t.Logf("expect restored already but got %v", err)
+		t.FailNow()
+	}
+	lunch, _ := history.As("ann").List(1500, 1500)
+	if len(lunch) != 1 || lunch[0].Title != "Lunch" || lunch[0].Id == other.Id {
+		t.Logf("lunch should be back under a new id %v", lunch)
+		t.FailNow()
+	}
+	// the old id tells where it went and the new one where it came from
+	changes = history.History(other.Id)
+	if len(changes) != 3 || changes[2].Kind != ChangeRestore || changes[2].After.Id != lunch[0].Id {
+		t.Logf("restore should be in the old history %v", changes)
+		t.FailNow()
+	}
+	if changes := history.History(lunch[0].Id); len(changes) != 1 || changes[0].Before.Id != other.Id {
+		t.Logf("restore should be in the new history %v", changes)
+		t.FailNow()
+	}
+	if _, _, err := history.Undo("ann", lunch[0].Id); err != nil {
+		t.Logf("undo create failed: %s", err)
+		t.FailNow()
+	}
+	if evts, _ := ann.List(1500, 1500); len(evts) != 0 {
+		t.Logf("lunch should be gone %v", evts)
+		t.FailNow()
+	}
+
+	from := time.Date(2026, 10, 18, 9, 2, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 18, 9, 3, 0, 0, time.UTC)
+	if changes := history.Changes(from, to); len(changes) != 2 || changes[0].Actor != "bob" || changes[1].EventId != other.Id {
+		t.Logf("wrong changes %v", changes)
+		t.FailNow()
+	}
+	if len(history.Changes(time.Time{}, time.Time{})) != 8 {
+		t.Logf("expect 8 changes")
+		t.FailNow()
+	}
+}
+
+func TestHistoryStore_Batch(t *testing.T) {
+	history := NewHistoryStore(NewMemoryStore(StoreOptions{}), HistoryOptions{})
+	store := history.As("sync")
+	a, _, _ := store.Create(Event{CalendarEvent: CalendarEvent{0, 0, 10}})
+	first, second := a, a
+	first.Title = "First"
+	second.Title, second.Version = "Second", 0
+	results, err := store.Batch([]BatchOp{{BatchUpdate, first}, {BatchUpdate, second}, {BatchCreate, Event{}}, {BatchDelete, Event{CalendarEvent: CalendarEvent{Id: a.Id}}}})
+	if err != nil || len(results) != 4 {
+		t.Logf("batch failed: %v", err)
+		t.FailNow()
+	}
+	changes := history.History(a.Id)
+	if len(changes) != 4 || changes[2].Before.Title != "First" || changes[2].After.Title != "Second" || changes[3].Before.Version != 3 {
+		t.Logf("wrong history %v", changes)
+		t.FailNow()
+	}
+	// a failed batch is not recorded
+	if _, err := store.Batch([]BatchOp{{BatchCreate, Event{}}, {BatchDelete, a}}); err == nil || len(history.Changes(time.Time{}, time.Time{})) != 5 {
+		t.Logf("failed batch should not be recorded: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestHistoryStore_Concurrent(t *testing.T) {
+	history := NewHistoryStore(NewMemoryStore(StoreOptions{}), HistoryOptions{})
+	created, _, _ := history.As("setup").Create(Event{CalendarEvent: CalendarEvent{0, 0, 10}})
+	var wg sync.WaitGroup
+	for _, actor := range []string{"ann", "bob", "carol", "dan"} {
+		wg.Add(1)
+		go func(store Store) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				evt, _ := store.Get(created.Id)
+				evt.End++
+				// stale versions fail, the others are recorded
+				store.Update(evt)
+				history.History(created.Id)
+			}
+		}(history.As(actor))
+	}
+	wg.Wait()
+	changes := history.History(created.Id)
+	for i := 1; i < len(changes); i++ {
+		if changes[i].Before.Version != changes[i-1].After.Version || changes[i].After.Version != changes[i-1].After.Version+1 {
+			t.Logf("changes out of order at %d", i)
+			t.FailNow()
+		}
+	}
+	if evt, _ := history.As("x").Get(created.Id); evt.Version != int64(len(changes)) {
+		t.Logf("expect version %d but got %d", len(changes), evt.Version)
+		t.FailNow()
+	}
+}
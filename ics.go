@@ -0,0 +1,566 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// error of ParseICS with the line number it happened on, starting from 1
+// Line is 0 when the input has no lines, e.g. ParseJCal
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (c *ParseError) Error() string {
+	if c.Line == 0 {
+		return c.Err.Error()
+	}
+	return fmt.Sprintf("line %d: %s", c.Line, c.Err)
+}
+
+// so errors.Cause returns the underlying error
+func (c *ParseError) Cause() error {
+	return c.Err
+}
+
+func parseErrorf(line int, format string, args ...interface{}) error {
+	return &ParseError{line, errors.Errorf(format, args...)}
+}
+
+// one content line of iCalendar, e.g. DTSTART;TZID=America/New_York:20261018T090000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+	Line   int
+}
+
+func (c *icsProperty) param(name string) string {
+	return c.Params[name]
+}
+
+// BEGIN ... END block, e.g. VEVENT
+type icsComponent struct {
+	Name       string
+	Line       int
+	Properties []*icsProperty
+	Children   []*icsComponent
+}
+
+func (c *icsComponent) get(name string) *icsProperty {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+func (c *icsComponent) all(name string) (ret []*icsProperty) {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			ret = append(ret, prop)
+		}
+	}
+	return
+}
+
+// read the unfolded content lines, the line number is the first physical line of each
+func readICSLines(r io.Reader, fn func(line string, number int) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var current strings.Builder
+	start, number := 0, 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if len(text) > 0 && (text[0] == ' ' || text[0] == '\t') {
+			if start == 0 {
+				return parseErrorf(number, "folded line without a line before")
+			}
+			current.WriteString(text[1:])
+			continue
+		}
+		if start > 0 {
+			if err := fn(current.String(), start); err != nil {
+				return err
+			}
+		}
+		current.Reset()
+		start = 0
+		if text != "" {
+			current.WriteString(text)
+			start = number
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &ParseError{number + 1, err}
+	}
+	if start > 0 {
+		return fn(current.String(), start)
+	}
+	return nil
+}
+
+// split name;param=value;param="quoted:value":value
+func parseICSProperty(line string, number int) (*icsProperty, error) {
+	prop := &icsProperty{Params: make(map[string]string), Line: number}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, parseErrorf(number, "invalid content line %q", line)
+	}
+	prop.Name = strings.ToUpper(line[:i])
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, parseErrorf(number, "invalid parameter in %q", line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		j := eq + 1
+		var value string
+		if j < len(rest) && rest[j] == '"' {
+			end := strings.IndexByte(rest[j+1:], '"')
+			if end < 0 {
+				return nil, parseErrorf(number, "unterminated quote in %q", line)
+			}
+			value = rest[j+1 : j+1+end]
+			j += end + 2
+		} else {
+			end := strings.IndexAny(rest[j:], ";:")
+			if end < 0 {
+				return nil, parseErrorf(number, "missing value in %q", line)
+			}
+			value = rest[j : j+end]
+			j += end
+		}
+		if j >= len(rest) || rest[j] != ';' && rest[j] != ':' {
+			return nil, parseErrorf(number, "invalid parameter in %q", line)
+		}
+		prop.Params[name] = value
+		i += 1 + j
+	}
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+func parseICSComponents(r io.Reader) (ret []*icsComponent, err error) {
+	var stack []*icsComponent
+	err = readICSLines(r, func(line string, number int) error {
+		prop, err := parseICSProperty(line, number)
+		if err != nil {
+			return err
+		}
+		switch prop.Name {
+		case "BEGIN":
+			stack = append(stack, &icsComponent{Name: strings.ToUpper(prop.Value), Line: number})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return parseErrorf(number, "unexpected END:%s", prop.Value)
+			}
+			done := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				ret = append(ret, done)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, done)
+			}
+		default:
+			if len(stack) == 0 {
+				return parseErrorf(number, "property %s outside of any component", prop.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+		return nil
+	})
+	if err == nil && len(stack) > 0 {
+		err = parseErrorf(stack[len(stack)-1].Line, "BEGIN:%s is never ended", stack[len(stack)-1].Name)
+	}
+	return
+}
+
+// unescape TEXT values, \n \, \; and \\
+func unescapeICSText(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// parse [+-]P[nW][nD][T[nH][nM][nS]]
+func parseICSDuration(value string) (ret time.Duration, err error) {
+	s := value
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, errors.Errorf("invalid duration %q", value)
+	}
+	s = s[1:]
+	inTime := false
+	number := ""
+	for _, ch := range s {
+		switch {
+		case ch >= '0' && ch <= '9':
+			number += string(ch)
+			continue
+		case ch == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %q", value)
+		}
+		number = ""
+		switch {
+		case ch == 'W' && !inTime:
+			ret += time.Duration(n) * 7 * 24 * time.Hour
+		case ch == 'D' && !inTime:
+			ret += time.Duration(n) * 24 * time.Hour
+		case ch == 'H' && inTime:
+			ret += time.Duration(n) * time.Hour
+		case ch == 'M' && inTime:
+			ret += time.Duration(n) * time.Minute
+		case ch == 'S' && inTime:
+			ret += time.Duration(n) * time.Second
+		default:
+			return 0, errors.Errorf("invalid duration %q", value)
+		}
+	}
+	if number != "" {
+		return 0, errors.Errorf("invalid duration %q", value)
+	}
+	return sign * ret, nil
+}
+
+// how a DATE or DATE-TIME value is kept
+type icsTimeKind int
+
+const (
+	icsUTC      icsTimeKind = iota // ends with Z
+	icsZoned                       // with TZID
+	icsFloating                    // no zone at all
+	icsDate                        // VALUE=DATE
+)
+
+// zones of the calendar by TZID
+type icsZones struct {
+	vtimezones map[string]*vtimezone
+	locations  map[string]*time.Location
+}
+
+// resolve TZID to a location, IANA names are preferred as they know every DST rule
+// otherwise the location is built from the rules of VTIMEZONE
+func (c *icsZones) location(tzid string) (*time.Location, error) {
+	if loc, ok := c.locations[tzid]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(tzid)
+	if err != nil || tzid == "" || tzid == "Local" {
+		zone, ok := c.vtimezones[tzid]
+		if !ok {
+			return nil, errors.Errorf("unknown TZID %q", tzid)
+		}
+		if loc, err = zone.location(tzid); err != nil {
+			return nil, err
+		}
+	}
+	c.locations[tzid] = loc
+	return loc, nil
+}
+
+// parse one DATE or DATE-TIME value of prop, the wall clock is returned as if in UTC
+// for icsUTC and icsZoned the second value is the real instant
+func (c *icsZones) parseTime(prop *icsProperty, value string) (wall, instant time.Time, kind icsTimeKind, loc *time.Location, err error) {
+	if strings.EqualFold(prop.param("VALUE"), "DATE") || len(value) == 8 {
+		kind = icsDate
+		if wall, err = time.Parse("20060102", value); err != nil {
+			err = parseErrorf(prop.Line, "invalid date %q of %s", value, prop.Name)
+		}
+		return
+	}
+	if strings.HasSuffix(value, "Z") {
+		kind = icsUTC
+		if wall, err = time.Parse("20060102T150405Z", value); err != nil {
+			err = parseErrorf(prop.Line, "invalid date time %q of %s", value, prop.Name)
+		}
+		instant, loc = wall, time.UTC
+		return
+	}
+	if wall, err = time.Parse("20060102T150405", value); err != nil {
+		err = parseErrorf(prop.Line, "invalid date time %q of %s", value, prop.Name)
+		return
+	}
+	tzid := prop.param("TZID")
+	if tzid == "" {
+		kind = icsFloating
+		return
+	}
+	kind = icsZoned
+	if loc, err = c.location(tzid); err != nil {
+		err = &ParseError{prop.Line, err}
+		return
+	}
+	instant = WallClockIn(wall, loc)
+	return
+}
+
+// parse a list of EXDATE or RDATE into the encoding of an event, see Event.RDates
+func (c *icsZones) parseDates(props []*icsProperty, wallClock bool) (ret []time.Time, err error) {
+	for _, prop := range props {
+		if strings.EqualFold(prop.param("VALUE"), "PERIOD") {
+			return nil, parseErrorf(prop.Line, "PERIOD of %s is not supported", prop.Name)
+		}
+		for _, value := range strings.Split(prop.Value, ",") {
+			wall, instant, kind, _, err := c.parseTime(prop, value)
+			if err != nil {
+				return nil, err
+			}
+			if wallClock || kind == icsFloating || kind == icsDate {
+				ret = append(ret, wall)
+			} else {
+				ret = append(ret, instant.UTC())
+			}
+		}
+	}
+	return
+}
+
+// ParseICS reads VCALENDAR streams into events, ids are given in the order of VEVENT from 0
+// VEVENT with RECURRENCE-ID becomes an override of the series with the same UID
+// malformed input returns a *ParseError with the line number
+func ParseICS(r io.Reader) (Events, error) {
+	components, err := parseICSComponents(r)
+	if err != nil {
+		return nil, err
+	}
+	return icsEvents(components)
+}
+
+// events out of parsed VCALENDAR components, shared by ParseICS and ParseJCal
+func icsEvents(components []*icsComponent) (Events, error) {
+	zones := &icsZones{vtimezones: make(map[string]*vtimezone), locations: make(map[string]*time.Location)}
+	var vevents []*icsComponent
+	for _, calendar := range components {
+		if calendar.Name != "VCALENDAR" {
+			return nil, parseErrorf(calendar.Line, "unexpected component %s, VCALENDAR is expected", calendar.Name)
+		}
+		for _, child := range calendar.Children {
+			switch child.Name {
+			case "VTIMEZONE":
+				tzid := child.get("TZID")
+				if tzid == nil {
+					return nil, parseErrorf(child.Line, "VTIMEZONE without TZID")
+				}
+				zone, err := parseVTimezone(child)
+				if err != nil {
+					return nil, err
+				}
+				zones.vtimezones[tzid.Value] = zone
+			case "VEVENT":
+				vevents = append(vevents, child)
+			}
+		}
+	}
+
+	var ret Events
+	type override struct {
+		wall, instant time.Time
+		kind          icsTimeKind
+		evt           Event
+		line          int
+	}
+	var overrides []override
+	for _, vevent := range vevents {
+		evt, err := zones.parseVEvent(vevent)
+		if err != nil {
+			return nil, err
+		}
+		if prop := vevent.get("RECURRENCE-ID"); prop != nil {
+			wall, instant, kind, _, err := zones.parseTime(prop, prop.Value)
+			if err != nil {
+				return nil, err
+			}
+			overrides = append(overrides, override{wall, instant, kind, evt, prop.Line})
+			continue
+		}
+		evt.Id = len(ret)
+		ret = append(ret, evt)
+	}
+
+	// attach overrides to the series, the ones without series become normal events
+	series := make(map[string]int)
+	for i := range ret {
+		if ret[i].UID != "" {
+			series[ret[i].UID] = i
+		}
+	}
+	for _, o := range overrides {
+		idx, ok := series[o.evt.UID]
+		if !ok {
+			o.evt.Id = len(ret)
+			ret = append(ret, o.evt)
+			continue
+		}
+		// RECURRENCE-ID is in the same encoding as Start of the series
+		master := &ret[idx]
+		recurrenceId := o.instant.Unix()
+		if master.Floating || master.AllDay {
+			recurrenceId = o.wall.Unix()
+		} else if o.kind == icsFloating || o.kind == icsDate {
+			recurrenceId = WallClockIn(o.wall, master.zone()).Unix()
+		}
+		if err := master.Override(recurrenceId, o.evt); err != nil {
+			return nil, &ParseError{o.line, err}
+		}
+	}
+	return ret, nil
+}
+
+func (c *icsZones) parseVEvent(vevent *icsComponent) (evt Event, err error) {
+	dtstart := vevent.get("DTSTART")
+	if dtstart == nil {
+		return evt, parseErrorf(vevent.Line, "VEVENT without DTSTART")
+	}
+	wall, instant, kind, loc, err := c.parseTime(dtstart, dtstart.Value)
+	if err != nil {
+		return evt, err
+	}
+
+	// the end as wall clock for floating and date, real instant for the others
+	var endWall, endInstant time.Time
+	var endKind icsTimeKind
+	if dtend := vevent.get("DTEND"); dtend != nil {
+		if vevent.get("DURATION") != nil {
+			return evt, parseErrorf(dtend.Line, "DTEND and DURATION can not be used together")
+		}
+		if endWall, endInstant, endKind, _, err = c.parseTime(dtend, dtend.Value); err != nil {
+			return evt, err
+		}
+		if (endKind == icsDate) != (kind == icsDate) {
+			return evt, parseErrorf(dtend.Line, "DTEND and DTSTART should both be date or date time")
+		}
+	} else if prop := vevent.get("DURATION"); prop != nil {
+		duration, err := parseICSDuration(prop.Value)
+		if err != nil {
+			return evt, &ParseError{prop.Line, err}
+		}
+		endWall, endInstant, endKind = wall.Add(duration), instant.Add(duration), kind
+	} else if kind == icsDate {
+		// one day by default
+		endWall, endKind = wall.AddDate(0, 0, 1), kind
+	} else {
+		endWall, endInstant, endKind = wall, instant, kind
+	}
+
+	switch kind {
+	case icsDate:
+		last := DateOf(endWall).AddDays(-1)
+		if last.Before(DateOf(wall)) {
+			last = DateOf(wall)
+		}
+		evt = NewAllDayEvent(0, DateOf(wall), last)
+	case icsFloating:
+		if endKind != icsFloating {
+			return evt, parseErrorf(dtstart.Line, "DTSTART is floating but DTEND is not")
+		}
+		evt = NewFloatingEvent(0, wall, endWall)
+	default:
+		evt = NewZonedEvent(0, instant, endInstant)
+		evt.Zone = loc
+	}
+	if evt.Start < 0 {
+		return evt, parseErrorf(dtstart.Line, "DTSTART %s is out of range, events start from 1970", dtstart.Value)
+	}
+	if !evt.IsValid() {
+		return evt, parseErrorf(dtstart.Line, "event ends before it starts")
+	}
+
+	for _, prop := range vevent.Properties {
+		switch prop.Name {
+		case "UID":
+			evt.UID = unescapeICSText(prop.Value)
+		case "SUMMARY":
+			evt.Title = unescapeICSText(prop.Value)
+		case "DESCRIPTION":
+			evt.Description = unescapeICSText(prop.Value)
+		case "LOCATION":
+			evt.Location = unescapeICSText(prop.Value)
+		case "ORGANIZER":
+			evt.Organizer = parseICSAttendee(prop)
+		case "ATTENDEE":
+			evt.Attendees = append(evt.Attendees, parseICSAttendee(prop))
+		case "RRULE":
+			if evt.Recurrence != nil {
+				return evt, parseErrorf(prop.Line, "more than one RRULE")
+			}
+			if evt.Recurrence, err = ParseRecurrenceRule(prop.Value); err != nil {
+				return evt, &ParseError{prop.Line, err}
+			}
+		default:
+			if strings.HasPrefix(prop.Name, "X-") {
+				if evt.Metadata == nil {
+					evt.Metadata = make(map[string]string)
+				}
+				evt.Metadata[prop.Name] = unescapeICSText(prop.Value)
+			}
+		}
+	}
+	wallClock := evt.Floating || evt.AllDay
+	if evt.RDates, err = c.parseDates(vevent.all("RDATE"), wallClock); err != nil {
+		return
+	}
+	if evt.ExDates, err = c.parseDates(vevent.all("EXDATE"), wallClock); err != nil {
+		return
+	}
+	sortTimes(evt.RDates)
+	sortTimes(evt.ExDates)
+	return
+}
+
+func parseICSAttendee(prop *icsProperty) Attendee {
+	email := prop.Value
+	if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+		email = email[7:]
+	}
+	return Attendee{
+		Name:   prop.param("CN"),
+		Email:  email,
+		Status: ParseRSVPStatus(prop.param("PARTSTAT")),
+	}
+}
+
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+}
@@ -0,0 +1,384 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const defaultProdId = "-//waters222//calendar-test//EN"
+
+// recurring events without end are given VTIMEZONE rules for this many years
+const vtimezoneYears = 10
+
+type ICSOptions struct {
+	// PRODID of VCALENDAR, a default one is used when empty
+	ProdId string
+	// DTSTAMP of every VEVENT, now when zero
+	Stamp time.Time
+	// conflicts found on the events, each event gets X-CONFLICTS-WITH listing the UIDs
+	// of the events it conflicts with
+	Conflicts []CalendarPair
+}
+
+// UID of the event, events without one get <id>@calendar-test
+func (c *Event) uid() string {
+	if c.UID != "" {
+		return c.UID
+	}
+	return fmt.Sprintf("%d@calendar-test", c.Id)
+}
+
+// RRULE value of the event, UNTIL of floating and all-day events is wall clock
+func (c *Event) rrule() string {
+	switch {
+	case c.AllDay:
+		return c.Recurrence.format("20060102")
+	case c.Floating:
+		return c.Recurrence.format("20060102T150405")
+	}
+	return c.Recurrence.String()
+}
+
+// the events as UTC events, so they can be written by WriteICS
+func (c CalendarEvents) Events() Events {
+	ret := make(Events, len(c))
+	for i := range c {
+		ret[i] = Event{CalendarEvent: c[i]}
+	}
+	return ret
+}
+
+// WriteICS writes the events as one VCALENDAR, with VTIMEZONE for every zone used
+// zones are identified by name, only the first location of a name is written
+// overrides become VEVENT with RECURRENCE-ID after their series
+func WriteICS(w io.Writer, evts Events, opts ICSOptions) error {
+	out := &icsWriter{w: bufio.NewWriter(w)}
+	stamp := opts.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	prodId := opts.ProdId
+	if prodId == "" {
+		prodId = defaultProdId
+	}
+	conflicts := make(map[int][]int)
+	for _, pair := range opts.Conflicts {
+		conflicts[pair.FirstId] = append(conflicts[pair.FirstId], pair.SecondId)
+		conflicts[pair.SecondId] = append(conflicts[pair.SecondId], pair.FirstId)
+	}
+	index := evts.Index()
+
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", escapeICSText(prodId))
+	for _, zone := range icsZonesOf(evts) {
+		out.vtimezone(zone.loc.String(), newVTimezone(zone.loc, zone.from, zone.to))
+	}
+	for i := range evts {
+		evt := &evts[i]
+		out.line("BEGIN", "VEVENT")
+		out.line("UID", escapeICSText(evt.uid()))
+		out.line("DTSTAMP", stamp.UTC().Format("20060102T150405Z"))
+		out.event(evt, opts.Conflicts != nil)
+		if others := conflicts[evt.Id]; len(others) > 0 {
+			uids := make([]string, len(others))
+			for j, other := range others {
+				if evt, ok := index[other]; ok {
+					uids[j] = escapeICSText(evt.uid())
+				} else {
+					uids[j] = fmt.Sprintf("%d@calendar-test", other)
+				}
+			}
+			out.line("X-CONFLICTS-WITH", strings.Join(uids, ","))
+		}
+		out.line("END", "VEVENT")
+
+		recurrenceIds := make([]int64, 0, len(evt.Overrides))
+		for recurrenceId := range evt.Overrides {
+			recurrenceIds = append(recurrenceIds, recurrenceId)
+		}
+		sort.Slice(recurrenceIds, func(i, j int) bool {
+			return recurrenceIds[i] < recurrenceIds[j]
+		})
+		for _, recurrenceId := range recurrenceIds {
+			override := evt.Overrides[recurrenceId]
+			out.line("BEGIN", "VEVENT")
+			out.line("UID", escapeICSText(evt.uid()))
+			out.line("DTSTAMP", stamp.UTC().Format("20060102T150405Z"))
+			out.times("RECURRENCE-ID", evt, recurrenceId)
+			out.event(&override, opts.Conflicts != nil)
+			out.line("END", "VEVENT")
+		}
+	}
+	out.line("END", "VCALENDAR")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// a companion report of conflicts, one line per pair with both events, e.g.
+// Standup (#0) 2026-10-19 09:00 UTC -> 09:15 UTC <-> Review (#3) 2026-10-19 09:10 UTC -> 10:00 UTC
+func WriteConflictReport(w io.Writer, evts Events, pairs []CalendarPair) error {
+	index := evts.Index()
+	describe := func(id int) string {
+		if evt, ok := index[id]; ok {
+			return evt.ToString()
+		}
+		return fmt.Sprintf("Unknown event (#%d)", id)
+	}
+	if _, err := fmt.Fprintf(w, "%d conflicts\n", len(pairs)); err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		if _, err := fmt.Fprintf(w, "%s <-> %s\n", describe(pair.FirstId), describe(pair.SecondId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type icsZoneRange struct {
+	loc      *time.Location
+	from, to int64
+}
+
+// zones used by the events and the range of time each one has to cover, in order of first use
+func icsZonesOf(evts Events) (ret []*icsZoneRange) {
+	byName := make(map[string]*icsZoneRange)
+	var visit func(evt *Event)
+	visit = func(evt *Event) {
+		if evt.Floating || evt.AllDay || evt.Zone == nil || evt.Zone.String() == "UTC" {
+			return
+		}
+		last := evt.End
+		for _, rdate := range evt.RDates {
+			if rdate.Unix() > last {
+				last = rdate.Unix()
+			}
+		}
+		if evt.Recurrence != nil {
+			if evt.Recurrence.Until.IsZero() {
+				last = time.Unix(last, 0).AddDate(vtimezoneYears, 0, 0).Unix()
+			} else if evt.Recurrence.Until.Unix() > last {
+				last = evt.Recurrence.Until.Unix()
+			}
+		}
+		// whole years, so the rules of every season are there
+		start := time.Unix(evt.Start, 0).In(evt.Zone)
+		from := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+		to := time.Date(time.Unix(last, 0).UTC().Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+		zone, ok := byName[evt.Zone.String()]
+		if !ok {
+			zone = &icsZoneRange{evt.Zone, from, to}
+			byName[evt.Zone.String()] = zone
+			ret = append(ret, zone)
+		}
+		if from < zone.from {
+			zone.from = from
+		}
+		if to > zone.to {
+			zone.to = to
+		}
+		for _, override := range evt.Overrides {
+			visit(&override)
+		}
+	}
+	for i := range evts {
+		visit(&evts[i])
+	}
+	return
+}
+
+// writes content lines folded at 75 octets, the first error is kept
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (c *icsWriter) line(name, value string, params ...string) {
+	if c.err != nil {
+		return
+	}
+	line := name
+	for _, param := range params {
+		line += ";" + param
+	}
+	_, c.err = c.w.WriteString(foldICSLine(line + ":" + value))
+}
+
+// split the line into lines of at most 75 octets without breaking UTF-8, ended by CRLF
+func foldICSLine(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space takes one octet
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// escape TEXT values, the reverse of unescapeICSText
+func escapeICSText(value string) string {
+	return strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n").Replace(value)
+}
+
+// parameter value, quoted when it has any of ;:, and without " as it can not be escaped
+func icsParam(name, value string) string {
+	value = strings.ReplaceAll(value, "\"", "'")
+	if strings.ContainsAny(value, ";:,") {
+		return name + "=\"" + value + "\""
+	}
+	return name + "=" + value
+}
+
+// DTSTART, DTEND, RDATE and the like with values in the encoding of evt
+func (c *icsWriter) times(name string, evt *Event, values ...int64) {
+	formatted := make([]string, len(values))
+	var params []string
+	for i, value := range values {
+		t := time.Unix(value, 0).UTC()
+		switch {
+		case evt.AllDay:
+			formatted[i] = t.Format("20060102")
+		case evt.Floating:
+			formatted[i] = t.Format("20060102T150405")
+		case evt.Zone == nil || evt.Zone.String() == "UTC":
+			formatted[i] = t.Format("20060102T150405Z")
+		default:
+			formatted[i] = t.In(evt.Zone).Format("20060102T150405")
+		}
+	}
+	switch {
+	case evt.AllDay:
+		params = []string{"VALUE=DATE"}
+	case !evt.Floating && evt.Zone != nil && evt.Zone.String() != "UTC":
+		params = []string{icsParam("TZID", evt.Zone.String())}
+	}
+	c.line(name, strings.Join(formatted, ","), params...)
+}
+
+func unixTimes(times []time.Time) []int64 {
+	ret := make([]int64, len(times))
+	for i, t := range times {
+		ret[i] = t.Unix()
+	}
+	return ret
+}
+
+// everything of the VEVENT after UID and DTSTAMP
+func (c *icsWriter) event(evt *Event, skipConflicts bool) {
+	c.times("DTSTART", evt, evt.Start)
+	if evt.AllDay {
+		// DTEND of dates is the day after
+		c.times("DTEND", evt, evt.End+1)
+	} else {
+		c.times("DTEND", evt, evt.End)
+	}
+	if evt.Recurrence != nil {
+		c.line("RRULE", evt.rrule())
+	}
+	if len(evt.RDates) > 0 {
+		c.times("RDATE", evt, unixTimes(evt.RDates)...)
+	}
+	if len(evt.ExDates) > 0 {
+		c.times("EXDATE", evt, unixTimes(evt.ExDates)...)
+	}
+	if evt.Title != "" {
+		c.line("SUMMARY", escapeICSText(evt.Title))
+	}
+	if evt.Description != "" {
+		c.line("DESCRIPTION", escapeICSText(evt.Description))
+	}
+	if evt.Location != "" {
+		c.line("LOCATION", escapeICSText(evt.Location))
+	}
+	if evt.Organizer.Name != "" || evt.Organizer.Email != "" {
+		c.attendee("ORGANIZER", &evt.Organizer, false)
+	}
+	for i := range evt.Attendees {
+		c.attendee("ATTENDEE", &evt.Attendees[i], true)
+	}
+	keys := make([]string, 0, len(evt.Metadata))
+	for key := range evt.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := icsExtensionName(key)
+		if skipConflicts && name == "X-CONFLICTS-WITH" {
+			continue
+		}
+		c.line(name, escapeICSText(evt.Metadata[key]))
+	}
+}
+
+func (c *icsWriter) attendee(name string, attendee *Attendee, status bool) {
+	var params []string
+	if attendee.Name != "" {
+		params = append(params, icsParam("CN", attendee.Name))
+	}
+	if status {
+		params = append(params, "PARTSTAT="+attendee.Status.String())
+	}
+	// without an email it is only known by CN, mailto: without an address is still a valid URI
+	c.line(name, "mailto:"+attendee.Email, params...)
+}
+
+// metadata key as an X- property name, other characters than letters, digits and - become -
+func icsExtensionName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, key)
+	if !strings.HasPrefix(name, "X-") {
+		name = "X-" + name
+	}
+	return name
+}
+
+func (c *icsWriter) vtimezone(tzid string, zone *vtimezone) {
+	c.line("BEGIN", "VTIMEZONE")
+	c.line("TZID", tzid)
+	for _, observance := range zone.observances {
+		kind := "STANDARD"
+		if observance.daylight {
+			kind = "DAYLIGHT"
+		}
+		c.line("BEGIN", kind)
+		c.line("DTSTART", observance.start.Format("20060102T150405"))
+		c.line("TZOFFSETFROM", formatUTCOffset(observance.offsetFrom))
+		c.line("TZOFFSETTO", formatUTCOffset(observance.offsetTo))
+		if observance.name != "" {
+			c.line("TZNAME", escapeICSText(observance.name))
+		}
+		if len(observance.rdates) > 0 {
+			rdates := make([]string, len(observance.rdates))
+			for i, rdate := range observance.rdates {
+				rdates[i] = rdate.Format("20060102T150405")
+			}
+			c.line("RDATE", strings.Join(rdates, ","))
+		}
+		c.line("END", kind)
+	}
+	c.line("END", "VTIMEZONE")
+}
@@ -0,0 +1,239 @@
+package calendar
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// same events, zones are compared by name as each import loads its own locations
+func sameImportedEvents(left, right Events) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	strip := func(evt Event) (Event, string) {
+		name := ""
+		if evt.Zone != nil {
+			name = evt.Zone.String()
+		}
+		evt.Zone = nil
+		return evt, name
+	}
+	for i := range left {
+		l, lz := strip(left[i])
+		r, rz := strip(right[i])
+		if lz != rz || len(l.Overrides) != len(r.Overrides) {
+			return false
+		}
+		for recurrenceId, override := range l.Overrides {
+			other, ok := r.Overrides[recurrenceId]
+			if !ok || !sameImportedEvents(Events{override}, Events{other}) {
+				return false
+			}
+		}
+		l.Overrides, r.Overrides = nil, nil
+		if !reflect.DeepEqual(l, r) {
+			return false
+		}
+	}
+	return true
+}
+
+const testRoundTripICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:focus@test
+SUMMARY:Focus time
+DTSTART:20261019T140000
+DTEND:20261019T160000
+RRULE:FREQ=WEEKLY;UNTIL=20261130T140000;BYDAY=MO,WE
+EXDATE:20261021T140000
+RDATE:20261024T140000
+END:VEVENT
+BEGIN:VEVENT
+UID:trip@test
+SUMMARY:Trip
+DTSTART;VALUE=DATE:20261101
+DTEND;VALUE=DATE:20261104
+RRULE:FREQ=MONTHLY;UNTIL=20270301
+END:VEVENT
+BEGIN:VEVENT
+UID:nyc@test
+SUMMARY:NYC sync
+DTSTART;TZID=America/New_York:20261026T090000
+DTEND;TZID=America/New_York:20261026T093000
+RRULE:FREQ=WEEKLY;COUNT=4
+END:VEVENT
+BEGIN:VEVENT
+UID:nyc@test
+RECURRENCE-ID;TZID=America/New_York:20261102T090000
+SUMMARY:NYC sync, moved
+DTSTART;TZID=America/New_York:20261102T110000
+DTEND;TZID=America/New_York:20261102T113000
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestWriteICSRoundTrip(t *testing.T) {
+	loadLocation(t, "America/New_York")
+	for _, input := range []string{testICS, testRoundTripICS} {
+		evts, err := ParseICS(strings.NewReader(input))
+		if err != nil {
+			t.Logf("parse failed: %s", err)
+			t.FailNow()
+		}
+		var b bytes.Buffer
+		if err := WriteICS(&b, evts, ICSOptions{}); err != nil {
+			t.Logf("write failed: %s", err)
+			t.FailNow()
+		}
+		again, err := ParseICS(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Logf("parse of export failed: %s\n%s", err, b.String())
+			t.FailNow()
+		}
+		if !sameImportedEvents(evts, again) {
+			t.Logf("round trip changed the events:\n%s", b.String())
+			t.FailNow()
+		}
+
+		// occurrences are same as well, so zones still know their DST rules
+		windowStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+		windowEnd := time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+		before := ExpandEvents(evts, windowStart, windowEnd, time.UTC)
+		after := ExpandEvents(again, windowStart, windowEnd, time.UTC)
+		if len(before) != len(after) {
+			t.Logf("expect %d occurrences but got %d", len(before), len(after))
+			t.FailNow()
+		}
+		for i := range before {
+			if before[i].CalendarEvent != after[i].CalendarEvent {
+				t.Logf("occurrence %d changed from %s to %s", i, before[i].ToString(), after[i].ToString())
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestWriteICSFormat(t *testing.T) {
+	evt := NewZonedEvent(7, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC))
+	evt.Title = strings.Repeat("很长的标题, ", 20)
+	evt.Description = "first line\nsecond; line\\"
+	evt.Attendees = []Attendee{{Name: "Doe, John", Email: "john@example.com", Status: RSVPTentative}, {Name: "Bob"}}
+	// names without an email, e.g. from CSV, are kept
+	evt.Organizer = Attendee{Name: "Ann"}
+	evt.UID = "7,a;b@test"
+	evt.Metadata = map[string]string{"color": "red"}
+	stamp := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+
+	var b bytes.Buffer
+	if err := WriteICS(&b, Events{evt}, ICSOptions{Stamp: stamp}); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	output := b.String()
+	for _, expect := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"PRODID:" + defaultProdId + "\r\n",
+		"UID:7\\,a\\;b@test\r\n",
+		"DTSTAMP:20261001T080000Z\r\n",
+		"DTSTART:20261018T090000Z\r\n",
+		"DESCRIPTION:first line\\nsecond\\; line\\\\\r\n",
+		"ATTENDEE;CN=\"Doe, John\";PARTSTAT=TENTATIVE:mailto:john@example.com\r\n",
+		"ATTENDEE;CN=Bob;PARTSTAT=NEEDS-ACTION:mailto:\r\n",
+		"ORGANIZER;CN=Ann:mailto:\r\n",
+		"X-COLOR:red\r\n",
+	} {
+		if !strings.Contains(output, expect) {
+			t.Logf("missing %q in\n%s", expect, output)
+			t.FailNow()
+		}
+	}
+	for _, line := range strings.Split(output, "\r\n") {
+		if len(line) > 75 {
+			t.Logf("line longer than 75 octets: %q", line)
+			t.FailNow()
+		}
+	}
+
+	evts, err := ParseICS(strings.NewReader(output))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	if evts[0].Title != evt.Title || evts[0].Description != evt.Description || evts[0].UID != evt.UID ||
+		!reflect.DeepEqual(evts[0].Attendees, evt.Attendees) || evts[0].Organizer != evt.Organizer {
+		t.Logf("text changed: %q %q %q %v %v", evts[0].Title, evts[0].Description, evts[0].UID, evts[0].Attendees, evts[0].Organizer)
+		t.FailNow()
+	}
+}
+
+func TestWriteICSConflicts(t *testing.T) {
+	evts := CalendarEvents{{0, 100, 200}, {1, 150, 250}, {2, 180, 300}, {3, 400, 500}}.Events()
+	evts[1].UID = "second@test"
+	pairs := FindOverlapPairsBrutal(evts.CalendarEvents())
+
+	var b bytes.Buffer
+	if err := WriteICS(&b, evts, ICSOptions{Conflicts: pairs}); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	again, err := ParseICS(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	expect := []string{"second@test,2@calendar-test", "0@calendar-test,2@calendar-test", "0@calendar-test,second@test", ""}
+	for i := range again {
+		if got := again[i].Metadata["X-CONFLICTS-WITH"]; got != expect[i] {
+			t.Logf("event %d: expect %q but got %q", i, expect[i], got)
+			t.FailNow()
+		}
+	}
+
+	// exporting again with conflicts does not repeat the old ones
+	b.Reset()
+	if err := WriteICS(&b, again, ICSOptions{Conflicts: pairs[:1]}); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	if n := strings.Count(b.String(), "X-CONFLICTS-WITH"); n != 2 {
+		t.Logf("expect 2 X-CONFLICTS-WITH but got %d", n)
+		t.FailNow()
+	}
+
+	b.Reset()
+	if err := WriteConflictReport(&b, evts, pairs); err != nil {
+		t.Logf("report failed: %s", err)
+		t.FailNow()
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 || lines[0] != "3 conflicts" || !strings.Contains(lines[1], "(#0)") || !strings.Contains(lines[1], "<-> Untitled event (#1)") {
+		t.Logf("wrong report:\n%s", b.String())
+		t.FailNow()
+	}
+}
+
+func TestNewVTimezone(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	zone := newVTimezone(newYork, from, to)
+	// EST at the start, then every onset of EDT and EST grouped by RDATE
+	if len(zone.observances) != 3 || len(zone.observances[1].rdates) != 1 || len(zone.observances[2].rdates) != 1 {
+		t.Logf("wrong observances %v", zone.observances)
+		t.FailNow()
+	}
+	loc, err := zone.location("America/New_York")
+	if err != nil {
+		t.Logf("location failed: %s", err)
+		t.FailNow()
+	}
+	for at := from; at < to; at += 1800 {
+		if zoneAt(loc, at) != zoneAt(newYork, at) {
+			t.Logf("zones differ at %s: %v and %v", time.Unix(at, 0).UTC(), zoneAt(loc, at), zoneAt(newYork, at))
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const testICS = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//calendar//EN
+BEGIN:VTIMEZONE
+TZID:Custom Eastern
+BEGIN:STANDARD
+DTSTART:19701101T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:19700308T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:standup@test
+SUMMARY:Standup
+DTSTART:20261019T090000Z
+DURATION:PT15M
+RRULE:FREQ=DAILY;COUNT=5
+EXDATE:20261021T090000Z
+LOCATION:Room 1\, 2nd floor
+DESCRIPTION:daily sync\nbring
+  updates
+ORGANIZER;CN=Ann:mailto:ann@example.com
+ATTENDEE;CN="Bob; Jr";PARTSTAT=ACCEPTED:mailto:bob@example.com
+ATTENDEE;PARTSTAT=DECLINED:mailto:carl@example.com
+X-COLOR:blue
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@test
+RECURRENCE-ID:20261022T090000Z
+SUMMARY:Standup moved
+DTSTART:20261022T100000Z
+DTEND:20261022T101500Z
+END:VEVENT
+BEGIN:VEVENT
+UID:summer@test
+SUMMARY:Summer review
+DTSTART;TZID=Custom Eastern:20260701T090000
+DTEND;TZID=Custom Eastern:20260701T100000
+END:VEVENT
+BEGIN:VEVENT
+UID:winter@test
+SUMMARY:Winter review
+DTSTART;TZID=Custom Eastern:20261201T090000
+DTEND;TZID=Custom Eastern:20261201T100000
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday@test
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20261224
+DTEND;VALUE=DATE:20261226
+END:VEVENT
+BEGIN:VEVENT
+UID:lunch@test
+SUMMARY:Lunch
+DTSTART:20261019T120000
+DTEND:20261019T130000
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICS(t *testing.T) {
+	evts, err := ParseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	if len(evts) != 5 {
+		t.Logf("expect 5 events but got %d", len(evts))
+		t.FailNow()
+	}
+	for i := range evts {
+		if evts[i].Id != i {
+			t.Logf("event %d has id %d", i, evts[i].Id)
+			t.FailNow()
+		}
+	}
+
+	standup := evts[0]
+	if standup.UID != "standup@test" || standup.Title != "Standup" ||
+		standup.Location != "Room 1, 2nd floor" || standup.Description != "daily sync\nbring updates" {
+		t.Logf("wrong text of standup: %q %q %q %q", standup.UID, standup.Title, standup.Location, standup.Description)
+		t.FailNow()
+	}
+	if standup.End-standup.Start != 15*60 || standup.Zone != time.UTC {
+		t.Logf("wrong time of standup: %s", standup.ToString())
+		t.FailNow()
+	}
+	if standup.Organizer.Name != "Ann" || standup.Organizer.Email != "ann@example.com" {
+		t.Logf("wrong organizer %s", standup.Organizer.ToString())
+		t.FailNow()
+	}
+	if len(standup.Attendees) != 2 || standup.Attendees[0].Name != "Bob; Jr" ||
+		standup.Attendees[0].Status != RSVPAccepted || standup.Attendees[1].Status != RSVPDeclined {
+		t.Logf("wrong attendees %v", standup.Attendees)
+		t.FailNow()
+	}
+	if standup.Metadata["X-COLOR"] != "blue" {
+		t.Logf("missing X-COLOR in %v", standup.Metadata)
+		t.FailNow()
+	}
+
+	windowStart := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).Unix()
+	windowEnd := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC).Unix()
+	var got []string
+	for _, occurrence := range standup.Occurrences(windowStart, windowEnd, time.UTC) {
+		got = append(got, time.Unix(occurrence.Start, 0).UTC().Format("01-02 15:04"))
+	}
+	expect := []string{"10-19 09:00", "10-20 09:00", "10-22 10:00", "10-23 09:00"}
+	if !sameStrings(got, expect) {
+		t.Logf("expect occurrences %v but got %v", expect, got)
+		t.FailNow()
+	}
+
+	// -0400 in summer and -0500 in winter by VTIMEZONE
+	summer, winter := evts[1], evts[2]
+	if time.Unix(summer.Start, 0).UTC().Hour() != 13 || time.Unix(winter.Start, 0).UTC().Hour() != 14 {
+		t.Logf("wrong offsets: %s and %s", summer.ToString(), winter.ToString())
+		t.FailNow()
+	}
+	if summer.Zone == nil || summer.Zone.String() != "Custom Eastern" {
+		t.Logf("wrong zone %v", summer.Zone)
+		t.FailNow()
+	}
+
+	holiday := evts[3]
+	first, last := holiday.Dates()
+	if !holiday.AllDay || first.String() != "2026-12-24" || last.String() != "2026-12-25" {
+		t.Logf("wrong holiday: %s", holiday.ToString())
+		t.FailNow()
+	}
+
+	lunch := evts[4]
+	if !lunch.Floating || time.Unix(lunch.Start, 0).UTC().Hour() != 12 {
+		t.Logf("wrong lunch: %s", lunch.ToString())
+		t.FailNow()
+	}
+}
+
+func TestParseICSIANAZone(t *testing.T) {
+	loc := loadLocation(t, "America/New_York")
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART;TZID=America/New_York:20261101T013000",
+		"DTEND;TZID=America/New_York:20261101T030000",
+		"RRULE:FREQ=WEEKLY;COUNT=2",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	evts, err := ParseICS(strings.NewReader(input))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	evt := evts[0]
+	// the ambiguous 01:30 is the first one, in EDT
+	if evt.Zone.String() != loc.String() || time.Unix(evt.Start, 0).UTC().Hour() != 5 {
+		t.Logf("wrong start %s", evt.ToString())
+		t.FailNow()
+	}
+	// 1.5 hours by wall clock but DST ends in between
+	if evt.Duration() != 150*time.Minute {
+		t.Logf("expect 2.5 hours but got %s", evt.Duration())
+		t.FailNow()
+	}
+}
+
+// a weekly meeting in a zone only known by VTIMEZONE stays at 09:00 across the end of DST
+func TestParseICSVTimezoneRecurrence(t *testing.T) {
+	input := strings.Replace(testICS, "UID:summer@test", "UID:summer@test\nRRULE:FREQ=WEEKLY;COUNT=3", 1)
+	input = strings.Replace(input, "20260701T", "20261019T", 2)
+	evts, err := ParseICS(strings.NewReader(input))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	meeting := evts[1]
+	var got []string
+	for _, occurrence := range meeting.Occurrences(meeting.Start, meeting.Start+30*24*3600, time.UTC) {
+		got = append(got, time.Unix(occurrence.Start, 0).UTC().Format("01-02 15:04"))
+	}
+	// -0400 until November 1st, -0500 after it
+	expect := []string{"10-19 13:00", "10-26 13:00", "11-02 14:00"}
+	if !sameStrings(got, expect) {
+		t.Logf("expect occurrences %v but got %v", expect, got)
+		t.FailNow()
+	}
+}
+
+func TestParseICSErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		line  int
+	}{
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:no start\nEND:VEVENT\nEND:VCALENDAR", 2},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2026-10-18\nEND:VEVENT\nEND:VCALENDAR", 3},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261018T090000Z\nDURATION:1H\nEND:VEVENT\nEND:VCALENDAR", 4},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=Nowhere:20261018T090000\nEND:VEVENT\nEND:VCALENDAR", 3},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261018T090000Z\nRRULE:FREQ=HOURLY\nEND:VEVENT\nEND:VCALENDAR", 4},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261018T090000Z\nDTEND:20261018T080000Z\nEND:VEVENT\nEND:VCALENDAR", 3},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261018T090000Z\nEND:VCALENDAR", 4},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261018T090000Z\nEND:VEVENT", 1},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nNO COLON\nEND:VEVENT\nEND:VCALENDAR", 3},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nATTENDEE;CN=\"Bob:mailto:bob@example.com\nEND:VEVENT\nEND:VCALENDAR", 3},
+		{" folded\nBEGIN:VCALENDAR\nEND:VCALENDAR", 1},
+		{"SUMMARY:outside\n", 1},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nDTSTART:20261018T090000Z\nEND:VEVENT\nBEGIN:VEVENT\nUID:a\n" +
+			"RECURRENCE-ID:20261019T090000Z\nDTSTART:20261019T100000Z\nEND:VEVENT\nEND:VCALENDAR", 8},
+	}
+	for i, c := range cases {
+		_, err := ParseICS(strings.NewReader(c.input))
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Logf("case %d: expect ParseError but got %v", i, err)
+			t.FailNow()
+		}
+		if parseErr.Line != c.line {
+			t.Logf("case %d: expect line %d but got %s", i, c.line, err)
+			t.FailNow()
+		}
+	}
+
+	_, err := ParseICS(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:19691231T090000Z\nDTEND:20261018T090000Z\nEND:VEVENT\nEND:VCALENDAR"))
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.Line != 3 || !strings.Contains(err.Error(), "out of range") {
+		t.Logf("expect DTSTART out of range on line 3 but got %v", err)
+		t.FailNow()
+	}
+
+	_, err = ParseICS(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nDTSTART:20261018T090000Z\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:a\nRECURRENCE-ID:20261019T090000Z\nDTSTART:20261019T100000Z\nEND:VEVENT\nEND:VCALENDAR"))
+	if errors.Cause(err) != errorNoOccurrence {
+		t.Logf("expect no occurrence error but got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"PT15M":      15 * time.Minute,
+		"P1D":        24 * time.Hour,
+		"P1W":        7 * 24 * time.Hour,
+		"P1DT2H3M4S": 26*time.Hour + 3*time.Minute + 4*time.Second,
+		"-PT1H":      -time.Hour,
+		"+PT30S":     30 * time.Second,
+	}
+	for value, expect := range cases {
+		if got, err := parseICSDuration(value); err != nil || got != expect {
+			t.Logf("%s: expect %s but got %s %v", value, expect, got, err)
+			t.FailNow()
+		}
+	}
+	for _, value := range []string{"", "P", "PT", "1H", "PT1D", "P1H", "PT1", "PXM"} {
+		if _, err := parseICSDuration(value); err == nil {
+			t.Logf("%q should be invalid", value)
+			t.FailNow()
+		}
+	}
+}
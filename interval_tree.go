@@ -0,0 +1,225 @@
+package calendar
+
+import "sort"
+
+// IntervalTree is an augmented AVL tree of calendar events keyed by Start (ties broken by Id).
+// every node keeps the max End of its subtree so overlap queries can prune whole branches
+// Complexity: Insert / Delete O(log n), Overlapping O(log n + k)
+type IntervalTree struct {
+	root *intervalNode
+	mode BoundaryMode
+	// event ids to the original event, so we can delete by id
+	// nodes keep the closed version of the event, see BoundaryMode
+	index map[int]CalendarEvent
+}
+
+type intervalNode struct {
+	evt    CalendarEvent
+	maxEnd int64
+	height int
+	left   *intervalNode
+	right  *intervalNode
+}
+
+// build a tree from events, later events with duplicated id replace the earlier ones
+func NewIntervalTree(evts CalendarEvents) *IntervalTree {
+	return NewIntervalTreeMode(evts, BoundaryClosed)
+}
+
+// same as NewIntervalTree but events and queries are treated in given boundary mode
+func NewIntervalTreeMode(evts CalendarEvents, mode BoundaryMode) *IntervalTree {
+	tree := &IntervalTree{mode: mode, index: make(map[int]CalendarEvent, len(evts))}
+	for _, evt := range evts {
+		tree.Insert(evt)
+	}
+	return tree
+}
+
+func (c *IntervalTree) Len() int {
+	return len(c.index)
+}
+
+// insert the event, if an event with same id already exists it will be replaced
+func (c *IntervalTree) Insert(evt CalendarEvent) {
+	if c.index == nil {
+		c.index = make(map[int]CalendarEvent)
+	}
+	if old, ok := c.index[evt.Id]; ok {
+		c.removeNode(old)
+	}
+	c.index[evt.Id] = evt
+	// empty events never overlap, so they are only kept in index
+	if closed, ok := c.mode.Closed(evt); ok {
+		c.root = c.root.insert(closed)
+	}
+}
+
+// delete the event by id, return false if id does not exist
+func (c *IntervalTree) Delete(id int) bool {
+	old, ok := c.index[id]
+	if !ok {
+		return false
+	}
+	delete(c.index, id)
+	c.removeNode(old)
+	return true
+}
+
+func (c *IntervalTree) removeNode(evt CalendarEvent) {
+	if closed, ok := c.mode.Closed(evt); ok {
+		c.root = c.root.remove(closed)
+	}
+}
+
+// get the event by id
+func (c *IntervalTree) Get(id int) (CalendarEvent, bool) {
+	evt, ok := c.index[id]
+	return evt, ok
+}
+
+// return all events overlaps with start -> end, ordered by Start then Id
+// using same semantics as CalendarEvent.Overlaps in the boundary mode of the tree
+func (c *IntervalTree) Overlapping(start, end int64) (ret []CalendarEvent) {
+	query, ok := c.mode.Closed(CalendarEvent{Start: start, End: end})
+	if !ok {
+		return
+	}
+	c.root.collect(&query, &ret)
+	for i := range ret {
+		ret[i] = c.index[ret[i].Id]
+	}
+	return
+}
+
+// return all events in tree ordered by Start then Id
+func (c *IntervalTree) Events() CalendarEvents {
+	ret := make(CalendarEvents, 0, len(c.index))
+	for _, evt := range c.index {
+		ret = append(ret, evt)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return lessEvent(&ret[i], &ret[j])
+	})
+	return ret
+}
+
+func (c *intervalNode) collect(query *CalendarEvent, ret *[]CalendarEvent) {
+	// nothing in this subtree ends after the query starts
+	if c == nil || c.maxEnd < query.Start {
+		return
+	}
+	c.left.collect(query, ret)
+	// everything on the right starts after this node, so no need to go further
+	if c.evt.Start > query.End {
+		return
+	}
+	if query.isOverlap(c.evt) {
+		*ret = append(*ret, c.evt)
+	}
+	c.right.collect(query, ret)
+}
+
+func lessEvent(a, b *CalendarEvent) bool {
+	if a.Start != b.Start {
+		return a.Start < b.Start
+	}
+	return a.Id < b.Id
+}
+
+func (c *intervalNode) getHeight() int {
+	if c == nil {
+		return 0
+	}
+	return c.height
+}
+
+func (c *intervalNode) update() {
+	c.height = 1
+	c.maxEnd = c.evt.End
+	if c.left != nil {
+		c.height = c.left.height + 1
+		if c.left.maxEnd > c.maxEnd {
+			c.maxEnd = c.left.maxEnd
+		}
+	}
+	if c.right != nil {
+		if c.right.height+1 > c.height {
+			c.height = c.right.height + 1
+		}
+		if c.right.maxEnd > c.maxEnd {
+			c.maxEnd = c.right.maxEnd
+		}
+	}
+}
+
+func (c *intervalNode) rotateLeft() *intervalNode {
+	root := c.right
+	c.right = root.left
+	root.left = c
+	c.update()
+	root.update()
+	return root
+}
+
+func (c *intervalNode) rotateRight() *intervalNode {
+	root := c.left
+	c.left = root.right
+	root.right = c
+	c.update()
+	root.update()
+	return root
+}
+
+func (c *intervalNode) balance() *intervalNode {
+	c.update()
+	factor := c.left.getHeight() - c.right.getHeight()
+	if factor > 1 {
+		if c.left.left.getHeight() < c.left.right.getHeight() {
+			c.left = c.left.rotateLeft()
+		}
+		return c.rotateRight()
+	} else if factor < -1 {
+		if c.right.right.getHeight() < c.right.left.getHeight() {
+			c.right = c.right.rotateRight()
+		}
+		return c.rotateLeft()
+	}
+	return c
+}
+
+func (c *intervalNode) insert(evt CalendarEvent) *intervalNode {
+	if c == nil {
+		return &intervalNode{evt: evt, maxEnd: evt.End, height: 1}
+	}
+	if lessEvent(&evt, &c.evt) {
+		c.left = c.left.insert(evt)
+	} else {
+		c.right = c.right.insert(evt)
+	}
+	return c.balance()
+}
+
+func (c *intervalNode) remove(evt CalendarEvent) *intervalNode {
+	if c == nil {
+		return nil
+	}
+	if lessEvent(&evt, &c.evt) {
+		c.left = c.left.remove(evt)
+	} else if lessEvent(&c.evt, &evt) {
+		c.right = c.right.remove(evt)
+	} else {
+		if c.left == nil {
+			return c.right
+		} else if c.right == nil {
+			return c.left
+		}
+		// replace with the smallest node of right subtree
+		min := c.right
+		for min.left != nil {
+			min = min.left
+		}
+		c.evt = min.evt
+		c.right = c.right.remove(min.evt)
+	}
+	return c.balance()
+}
@@ -0,0 +1,126 @@
+package calendar
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func bruteOverlapping(evts []CalendarEvent, start, end int64) (ret []CalendarEvent) {
+	query := CalendarEvent{Start: start, End: end}
+	for _, evt := range evts {
+		if query.isOverlap(evt) {
+			ret = append(ret, evt)
+		}
+	}
+	return
+}
+
+func sameEventIds(left, right []CalendarEvent) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	ids := make(map[int]int)
+	for _, evt := range left {
+		ids[evt.Id]++
+	}
+	for _, evt := range right {
+		ids[evt.Id]--
+	}
+	for _, count := range ids {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIntervalTree_Overlapping(t *testing.T) {
+	tree := NewIntervalTree(CalendarEvents{{0, 0, 100}, {1, 101, 200}, {2, 0, 2000}, {3, 101, 200}})
+	if tree.Len() != 4 {
+		t.Logf("tree should have 4 events instead of %d", tree.Len())
+		t.FailNow()
+	}
+	// closed interval, touching end point should overlap
+	ret := tree.Overlapping(100, 100)
+	if len(ret) != 2 || ret[0].Id != 0 || ret[1].Id != 2 {
+		t.Logf("overlapping 100 -> 100 is wrong: %v", ret)
+		t.FailNow()
+	}
+	ret = tree.Overlapping(2001, 3000)
+	if len(ret) != 0 {
+		t.Logf("it should has no overlap: %v", ret)
+		t.FailNow()
+	}
+
+	tree = NewIntervalTree(testEvents)
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < 200; i++ {
+		start := r.Int63n(1100) - 50
+		end := start + r.Int63n(100)
+		if ret, baseline := tree.Overlapping(start, end), bruteOverlapping(testEvents, start, end); !sameEventIds(ret, baseline) {
+			t.Logf("overlapping %d -> %d got %d events, baseline %d", start, end, len(ret), len(baseline))
+			t.FailNow()
+		}
+	}
+}
+
+func TestIntervalTree_InsertDelete(t *testing.T) {
+	tree := NewIntervalTree(nil)
+	tree.Insert(CalendarEvent{0, 0, 100})
+	tree.Insert(CalendarEvent{1, 50, 150})
+	// replace event 0 by id
+	tree.Insert(CalendarEvent{0, 200, 300})
+	if tree.Len() != 2 {
+		t.Logf("tree should have 2 events instead of %d", tree.Len())
+		t.FailNow()
+	}
+	if ret := tree.Overlapping(0, 40); len(ret) != 0 {
+		t.Logf("replaced event should be gone: %v", ret)
+		t.FailNow()
+	}
+	if !tree.Delete(1) || tree.Delete(1) {
+		t.Log("delete should only succeed once")
+		t.FailNow()
+	}
+	if ret := tree.Overlapping(0, 1000); len(ret) != 1 || ret[0].Id != 0 {
+		t.Logf("only event 0 should be left: %v", ret)
+		t.FailNow()
+	}
+
+	// delete half of the random events and check against baseline
+	tree = NewIntervalTree(testEvents)
+	var left []CalendarEvent
+	for _, evt := range testEvents {
+		if evt.Id%2 == 0 {
+			tree.Delete(evt.Id)
+		} else {
+			left = append(left, evt)
+		}
+	}
+	if tree.Len() != len(left) || tree.root.height > 2*bitLen(len(left)) {
+		t.Logf("tree size %d height %d is wrong", tree.Len(), tree.root.height)
+		t.FailNow()
+	}
+	for start := int64(0); start < 1000; start += 37 {
+		if ret, baseline := tree.Overlapping(start, start+20), bruteOverlapping(left, start, start+20); !sameEventIds(ret, baseline) {
+			t.Logf("overlapping %d -> %d got %d events, baseline %d", start, start+20, len(ret), len(baseline))
+			t.FailNow()
+		}
+	}
+}
+
+func bitLen(n int) (ret int) {
+	for n > 0 {
+		ret++
+		n >>= 1
+	}
+	return
+}
+
+func Benchmark_intervalTree(b *testing.B) {
+	tree := NewIntervalTree(testEvents)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Overlapping(int64(i%1000), int64(i%1000)+10)
+	}
+}
@@ -0,0 +1,322 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jCal of RFC 7265 is iCalendar as JSON, a component is [name, properties, components]
+// and a property is [name, parameters, type, value...], so both go through the same
+// VCALENDAR components of ParseICS and WriteICS
+
+var errorInvalidJCal = errors.New("invalid jCal")
+
+// value type of the properties we know, the others are text
+var jcalTypes = map[string]string{
+	"DTSTART":       "date-time",
+	"DTEND":         "date-time",
+	"DTSTAMP":       "date-time",
+	"RECURRENCE-ID": "date-time",
+	"RDATE":         "date-time",
+	"EXDATE":        "date-time",
+	"DURATION":      "duration",
+	"RRULE":         "recur",
+	"TZOFFSETFROM":  "utc-offset",
+	"TZOFFSETTO":    "utc-offset",
+	"ORGANIZER":     "cal-address",
+	"ATTENDEE":      "cal-address",
+}
+
+// properties with a list of values, the others have one value even with a comma inside
+var jcalLists = map[string]bool{"RDATE": true, "EXDATE": true}
+
+// WriteJCal writes the events as a jCal vcalendar, same content as WriteICS
+func WriteJCal(w io.Writer, evts Events, opts ICSOptions) error {
+	var b bytes.Buffer
+	if err := WriteICS(&b, evts, opts); err != nil {
+		return err
+	}
+	components, err := parseICSComponents(&b)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(jcalComponent(components[0]))
+}
+
+// ParseJCal reads one jCal vcalendar, or an array of them, into events same as ParseICS
+func ParseJCal(r io.Reader) (Events, error) {
+	var v []interface{}
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errorInvalidJCal, "%s", err)
+	}
+	calendars := []interface{}{v}
+	if len(v) > 0 {
+		if _, ok := v[0].(string); !ok {
+			calendars = v
+		}
+	}
+	var components []*icsComponent
+	for _, calendar := range calendars {
+		component, err := icsComponentOf(calendar)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, component)
+	}
+	return icsEvents(components)
+}
+
+func jcalComponent(component *icsComponent) []interface{} {
+	properties := make([]interface{}, 0, len(component.Properties))
+	for _, prop := range component.Properties {
+		properties = append(properties, jcalProperty(prop))
+	}
+	children := make([]interface{}, 0, len(component.Children))
+	for _, child := range component.Children {
+		children = append(children, jcalComponent(child))
+	}
+	return []interface{}{strings.ToLower(component.Name), properties, children}
+}
+
+func jcalProperty(prop *icsProperty) []interface{} {
+	params := make(map[string]string)
+	for name, value := range prop.Params {
+		if name != "VALUE" {
+			params[strings.ToLower(name)] = value
+		}
+	}
+	kind, ok := jcalTypes[prop.Name]
+	if !ok {
+		kind = "text"
+	}
+	if kind == "date-time" && (strings.EqualFold(prop.param("VALUE"), "DATE") || len(prop.Value) == 8) {
+		kind = "date"
+	}
+	ret := []interface{}{strings.ToLower(prop.Name), params, kind}
+	values := []string{prop.Value}
+	if jcalLists[prop.Name] {
+		values = strings.Split(prop.Value, ",")
+	}
+	for _, value := range values {
+		switch kind {
+		case "date", "date-time":
+			ret = append(ret, jcalTime(value))
+		case "utc-offset":
+			ret = append(ret, jcalOffset(value))
+		case "recur":
+			ret = append(ret, jcalRecur(value))
+		case "text":
+			ret = append(ret, unescapeICSText(value))
+		default:
+			ret = append(ret, value)
+		}
+	}
+	return ret
+}
+
+// 20261018T090000Z to 2026-10-18T09:00:00Z and 20261018 to 2026-10-18
+func jcalTime(value string) string {
+	if len(value) < 8 {
+		return value
+	}
+	ret := value[0:4] + "-" + value[4:6] + "-" + value[6:8]
+	if len(value) >= 15 && value[8] == 'T' {
+		ret += "T" + value[9:11] + ":" + value[11:13] + ":" + value[13:15] + value[15:]
+	}
+	return ret
+}
+
+func icsTime(value string) string {
+	return strings.NewReplacer("-", "", ":", "").Replace(value)
+}
+
+// -0500 to -05:00
+func jcalOffset(value string) string {
+	if len(value) < 5 {
+		return value
+	}
+	ret := value[0:3] + ":" + value[3:5]
+	if len(value) == 7 {
+		ret += ":" + value[5:7]
+	}
+	return ret
+}
+
+// FREQ=WEEKLY;BYDAY=MO,WE to {"freq":"WEEKLY","byday":["MO","WE"]}
+func jcalRecur(value string) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.ToLower(kv[0])
+		var values []interface{}
+		for _, v := range strings.Split(kv[1], ",") {
+			if key == "until" {
+				values = append(values, jcalTime(v))
+			} else if n, err := strconv.Atoi(v); err == nil {
+				values = append(values, n)
+			} else {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 1 {
+			ret[key] = values[0]
+		} else {
+			ret[key] = values
+		}
+	}
+	return ret
+}
+
+func icsRecur(recur map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(recur))
+	for key := range recur {
+		keys = append(keys, key)
+	}
+	// FREQ first as some readers expect it, the rest does not matter
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "freq" || keys[j] == "freq" {
+			return keys[i] == "freq"
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values, ok := recur[key].([]interface{})
+		if !ok {
+			values = []interface{}{recur[key]}
+		}
+		formatted := make([]string, len(values))
+		for i, v := range values {
+			switch v := v.(type) {
+			case string:
+				if key == "until" {
+					v = icsTime(v)
+				}
+				formatted[i] = v
+			case float64:
+				formatted[i] = strconv.FormatInt(int64(v), 10)
+			default:
+				return "", errors.Wrapf(errorInvalidJCal, "recur %s has value %v", key, v)
+			}
+		}
+		parts = append(parts, strings.ToUpper(key)+"="+strings.Join(formatted, ","))
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// component out of [name, properties, components]
+func icsComponentOf(v interface{}) (*icsComponent, error) {
+	array, ok := v.([]interface{})
+	if !ok || len(array) != 3 {
+		return nil, errors.Wrap(errorInvalidJCal, "component should be [name, properties, components]")
+	}
+	name, ok1 := array[0].(string)
+	properties, ok2 := array[1].([]interface{})
+	children, ok3 := array[2].([]interface{})
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errors.Wrap(errorInvalidJCal, "component should be [name, properties, components]")
+	}
+	component := &icsComponent{Name: strings.ToUpper(name)}
+	for _, p := range properties {
+		prop, err := icsPropertyOf(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", name)
+		}
+		component.Properties = append(component.Properties, prop)
+	}
+	for _, child := range children {
+		c, err := icsComponentOf(child)
+		if err != nil {
+			return nil, err
+		}
+		component.Children = append(component.Children, c)
+	}
+	return component, nil
+}
+
+// property out of [name, parameters, type, value...]
+func icsPropertyOf(v interface{}) (*icsProperty, error) {
+	array, ok := v.([]interface{})
+	if !ok || len(array) < 4 {
+		return nil, errors.Wrap(errorInvalidJCal, "property should be [name, parameters, type, value...]")
+	}
+	name, ok1 := array[0].(string)
+	params, ok2 := array[1].(map[string]interface{})
+	kind, ok3 := array[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errors.Wrap(errorInvalidJCal, "property should be [name, parameters, type, value...]")
+	}
+	prop := &icsProperty{Name: strings.ToUpper(name), Params: make(map[string]string)}
+	for key, value := range params {
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.Wrapf(errorInvalidJCal, "parameter %s of %s is not a string", key, name)
+		}
+		prop.Params[strings.ToUpper(key)] = s
+	}
+	if kind == "date" {
+		prop.Params["VALUE"] = "DATE"
+	}
+	values := make([]string, 0, len(array)-3)
+	for _, value := range array[3:] {
+		switch kind {
+		case "recur":
+			recur, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.Wrapf(errorInvalidJCal, "recur of %s is not an object", name)
+			}
+			s, err := icsRecur(recur)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+			continue
+		}
+		s, err := jcalScalar(name, kind, value)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "date", "date-time":
+			// the same as in iCalendar without - and :
+			s = icsTime(s)
+		case "utc-offset":
+			s = strings.ReplaceAll(s, ":", "")
+		case "text":
+			s = escapeICSText(s)
+		}
+		values = append(values, s)
+	}
+	prop.Value = strings.Join(values, ",")
+	return prop, nil
+}
+
+// a value other than recur as in iCalendar, integer, float and boolean values are JSON
+// numbers and booleans, the others strings
+func jcalScalar(name, kind string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		switch {
+		case kind == "integer" && v == float64(int64(v)):
+			return strconv.FormatInt(int64(v), 10), nil
+		case kind == "float":
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case bool:
+		if kind == "boolean" {
+			return strings.ToUpper(strconv.FormatBool(v)), nil
+		}
+	}
+	return "", errors.Wrapf(errorInvalidJCal, "value %v of %s is not a %s", value, name, kind)
+}
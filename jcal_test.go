@@ -0,0 +1,124 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestJCalRoundTrip(t *testing.T) {
+	loadLocation(t, "America/New_York")
+	for _, input := range []string{testICS, testRoundTripICS} {
+		evts, err := ParseICS(strings.NewReader(input))
+		if err != nil {
+			t.Logf("parse failed: %s", err)
+			t.FailNow()
+		}
+		var b bytes.Buffer
+		if err := WriteJCal(&b, evts, ICSOptions{Stamp: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+			t.Logf("write failed: %s", err)
+			t.FailNow()
+		}
+		again, err := ParseJCal(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Logf("parse of jCal failed: %s\n%s", err, b.String())
+			t.FailNow()
+		}
+		if !sameImportedEvents(evts, again) {
+			t.Logf("round trip changed the events:\n%s", b.String())
+			t.FailNow()
+		}
+	}
+}
+
+func TestWriteJCal(t *testing.T) {
+	evts, err := ParseICS(strings.NewReader(testRoundTripICS))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	var b bytes.Buffer
+	if err := WriteJCal(&b, evts[:2], ICSOptions{Stamp: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Logf("write failed: %s", err)
+		t.FailNow()
+	}
+	for _, expect := range []string{
+		`["vcalendar",[["version",{},"text","2.0"]`,
+		`["dtstamp",{},"date-time","2026-10-01T00:00:00Z"]`,
+		`["dtstart",{},"date-time","2026-10-19T14:00:00"]`,
+		`["rrule",{},"recur",{"byday":["MO","WE"],"freq":"WEEKLY","until":"2026-11-30T14:00:00"}]`,
+		`["rdate",{},"date-time","2026-10-24T14:00:00"]`,
+		`["dtstart",{},"date","2026-11-01"]`,
+	} {
+		if !strings.Contains(b.String(), expect) {
+			t.Logf("missing %s in %s", expect, b.String())
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseJCal(t *testing.T) {
+	input := `[["vcalendar", [], [
+		["vtimezone", [["tzid", {}, "text", "Fixed"]], [
+			["standard", [
+				["dtstart", {}, "date-time", "1970-01-01T00:00:00"],
+				["tzoffsetfrom", {}, "utc-offset", "+05:30"],
+				["tzoffsetto", {}, "utc-offset", "+05:30"]
+			], []]
+		]],
+		["vevent", [
+			["uid", {}, "text", "a"],
+			["dtstart", {"tzid": "Fixed"}, "date-time", "2026-10-18T09:00:00"],
+			["duration", {}, "duration", "PT1H"],
+			["summary", {}, "text", "a, b; c"],
+			["sequence", {}, "integer", 2],
+			["priority", {}, "integer", 1],
+			["x-ratio", {}, "float", 0.5],
+			["x-busy", {}, "boolean", true],
+			["rrule", {}, "recur", {"freq": "DAILY", "count": 3}],
+			["exdate", {"tzid": "Fixed"}, "date-time", "2026-10-19T09:00:00", "2026-10-20T09:00:00"]
+		], []]
+	]]]`
+	evts, err := ParseJCal(strings.NewReader(input))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	evt := evts[0]
+	if evt.Title != "a, b; c" || evt.Recurrence.Count != 3 || len(evt.ExDates) != 2 {
+		t.Logf("wrong event %s", evt.ToString())
+		t.FailNow()
+	}
+	if evt.Metadata["X-RATIO"] != "0.5" || evt.Metadata["X-BUSY"] != "TRUE" {
+		t.Logf("wrong metadata %v", evt.Metadata)
+		t.FailNow()
+	}
+	if time.Unix(evt.Start, 0).UTC().Format("15:04") != "03:30" || evt.Duration() != time.Hour {
+		t.Logf("wrong time %s", evt.ToString())
+		t.FailNow()
+	}
+
+	for _, input := range []string{
+		`{}`,
+		`["vcalendar", []]`,
+		`["vcalendar", [["version", {}, "text"]], []]`,
+		`["vcalendar", [], [["vevent", [["rrule", {}, "recur", "FREQ=DAILY"]], []]]]`,
+		`["vcalendar", [], [["vevent", [["dtstart", {"tzid": 1}, "date-time", "2026-10-18T09:00:00"]], []]]]`,
+		`["vcalendar", [], [["vevent", [["summary", {}, "text", 1]], []]]]`,
+		`["vcalendar", [], [["vevent", [["sequence", {}, "integer", 1.5]], []]]]`,
+	} {
+		if _, err := ParseJCal(strings.NewReader(input)); errors.Cause(err) != errorInvalidJCal {
+			t.Logf("%s: expect invalid jCal but got %v", input, err)
+			t.FailNow()
+		}
+	}
+	// errors of the content are the same as ParseICS without line
+	_, err = ParseJCal(strings.NewReader(`["vcalendar", [], [["vevent", [["summary", {}, "text", "x"]], []]]]`))
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.Line != 0 || parseErr.Error() != "VEVENT without DTSTART" {
+		t.Logf("wrong error %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,364 @@
+package calendar
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JSON encoding, times are RFC 3339 with second precision
+//
+//	CalendarEvent  {"id":3,"start":"2026-10-18T09:00:00Z","end":"2026-10-18T09:15:00Z"}
+//	CalendarPair   {"first":3,"second":7}
+//	Segment        {"start":"2026-10-18T09:00:00Z","end":"2026-10-18T09:15:00Z","ids":[3,7]}
+//
+// Event adds its other fields to the CalendarEvent ones, times are in the zone of the event,
+// floating times have no offset, e.g. 2026-10-18T09:00:00, and all-day events use dates,
+// e.g. {"start":"2026-12-24","end":"2026-12-25","allDay":true}, end is the last day included
+// zones not in the IANA database, e.g. from VTIMEZONE, carry their rules as base64 TZif in zoneData
+
+var errorInvalidJSON = errors.New("invalid JSON")
+
+func formatUTC(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+func parseRFC3339(value string) (int64, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, errors.Wrapf(errorInvalidJSON, "time %q is not RFC 3339", value)
+	}
+	return t.Unix(), nil
+}
+
+type calendarEventJSON struct {
+	Id    int    `json:"id"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (c CalendarEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(calendarEventJSON{c.Id, formatUTC(c.Start), formatUTC(c.End)})
+}
+
+func (c *CalendarEvent) UnmarshalJSON(data []byte) (err error) {
+	var v calendarEventJSON
+	if err = json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	c.Id = v.Id
+	if c.Start, err = parseRFC3339(v.Start); err != nil {
+		return
+	}
+	c.End, err = parseRFC3339(v.End)
+	return
+}
+
+type calendarPairJSON struct {
+	First  int `json:"first"`
+	Second int `json:"second"`
+}
+
+func (c CalendarPair) MarshalJSON() ([]byte, error) {
+	return json.Marshal(calendarPairJSON{c.FirstId, c.SecondId})
+}
+
+func (c *CalendarPair) UnmarshalJSON(data []byte) error {
+	var v calendarPairJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.FirstId, c.SecondId = v.First, v.Second
+	return nil
+}
+
+type segmentJSON struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Ids   []int  `json:"ids"`
+}
+
+func (c Segment) MarshalJSON() ([]byte, error) {
+	ids := c.Ids
+	if ids == nil {
+		ids = []int{}
+	}
+	return json.Marshal(segmentJSON{formatUTC(c.Start), formatUTC(c.End), ids})
+}
+
+func (c *Segment) UnmarshalJSON(data []byte) (err error) {
+	var v segmentJSON
+	if err = json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	c.Ids = v.Ids
+	if c.Start, err = parseRFC3339(v.Start); err != nil {
+		return
+	}
+	c.End, err = parseRFC3339(v.End)
+	return
+}
+
+type eventJSON struct {
+	Id           int               `json:"id"`
+	UID          string            `json:"uid,omitempty"`
+	Start        string            `json:"start"`
+	End          string            `json:"end"`
+	Zone         string            `json:"zone,omitempty"`
+	ZoneData     []byte            `json:"zoneData,omitempty"`
+	Floating     bool              `json:"floating,omitempty"`
+	AllDay       bool              `json:"allDay,omitempty"`
+	Title        string            `json:"title,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Location     string            `json:"location,omitempty"`
+	Organizer    *Attendee         `json:"organizer,omitempty"`
+	Attendees    []Attendee        `json:"attendees,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Recurrence   string            `json:"recurrence,omitempty"`
+	RDates       []string          `json:"rdates,omitempty"`
+	ExDates      []string          `json:"exdates,omitempty"`
+	Overrides    map[string]Event  `json:"overrides,omitempty"`
+	RecurrenceId string            `json:"recurrenceId,omitempty"`
+	Version      int64             `json:"version,omitempty"`
+}
+
+// t in the encoding of the event, see the JSON encoding above
+func (c *Event) formatTime(t int64) string {
+	switch {
+	case c.AllDay:
+		return time.Unix(t, 0).UTC().Format("2006-01-02")
+	case c.Floating:
+		return time.Unix(t, 0).UTC().Format("2006-01-02T15:04:05")
+	}
+	return time.Unix(t, 0).In(c.zone()).Format(time.RFC3339)
+}
+
+func (c *Event) parseTime(value string) (int64, error) {
+	layout := time.RFC3339
+	switch {
+	case c.AllDay:
+		layout = "2006-01-02"
+	case c.Floating:
+		layout = "2006-01-02T15:04:05"
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return 0, errors.Wrapf(errorInvalidJSON, "time %q is not in %s", value, layout)
+	}
+	return t.Unix(), nil
+}
+
+func (c *Event) toJSON() (ret eventJSON) {
+	// End of all-day events is the last second, so it is formatted as the last day
+	ret = eventJSON{
+		Id:          c.Id,
+		UID:         c.UID,
+		Start:       c.formatTime(c.Start),
+		End:         c.formatTime(c.End),
+		Floating:    c.Floating,
+		AllDay:      c.AllDay,
+		Title:       c.Title,
+		Description: c.Description,
+		Location:    c.Location,
+		Attendees:   c.Attendees,
+		Metadata:    c.Metadata,
+		Version:     c.Version,
+	}
+	if c.Zone != nil && !c.Floating && !c.AllDay {
+		ret.Zone = c.Zone.String()
+		ret.ZoneData = customZoneData(c.Zone)
+	}
+	if c.Organizer != (Attendee{}) {
+		organizer := c.Organizer
+		ret.Organizer = &organizer
+	}
+	if c.Recurrence != nil {
+		ret.Recurrence = c.rrule()
+	}
+	for _, rdate := range c.RDates {
+		ret.RDates = append(ret.RDates, c.formatTime(rdate.Unix()))
+	}
+	for _, exdate := range c.ExDates {
+		ret.ExDates = append(ret.ExDates, c.formatTime(exdate.Unix()))
+	}
+	if len(c.Overrides) > 0 {
+		ret.Overrides = make(map[string]Event, len(c.Overrides))
+		for recurrenceId, override := range c.Overrides {
+			ret.Overrides[c.formatTime(recurrenceId)] = override
+		}
+	}
+	return
+}
+
+func (c *Event) fromJSON(v *eventJSON) (err error) {
+	*c = Event{
+		CalendarEvent: CalendarEvent{Id: v.Id},
+		UID:           v.UID,
+		Floating:      v.Floating,
+		AllDay:        v.AllDay,
+		Title:         v.Title,
+		Description:   v.Description,
+		Location:      v.Location,
+		Attendees:     v.Attendees,
+		Metadata:      v.Metadata,
+		Version:       v.Version,
+	}
+	if v.Floating && v.AllDay {
+		return errors.Wrap(errorInvalidJSON, "event can not be both floating and all-day")
+	}
+	if v.Organizer != nil {
+		c.Organizer = *v.Organizer
+	}
+	if c.Start, err = c.parseTime(v.Start); err != nil {
+		return
+	}
+	if c.End, err = c.parseTime(v.End); err != nil {
+		return
+	}
+	if c.AllDay {
+		// to the last second of the last day
+		c.End += 24*3600 - 1
+	}
+	if v.Zone != "" && !c.Floating && !c.AllDay {
+		if len(v.ZoneData) > 0 {
+			if c.Zone, err = loadCustomZone(v.Zone, v.ZoneData); err != nil {
+				return errors.Wrapf(errorInvalidJSON, "zone data of %q: %s", v.Zone, err)
+			}
+		} else if c.Zone, err = time.LoadLocation(v.Zone); err != nil {
+			// zones only known by the sender without their rules keep the offset of start
+			start, _ := time.Parse(time.RFC3339, v.Start)
+			_, offset := start.Zone()
+			c.Zone, err = time.FixedZone(v.Zone, offset), nil
+		}
+	}
+	if v.Recurrence != "" {
+		if c.Recurrence, err = ParseRecurrenceRule(v.Recurrence); err != nil {
+			return
+		}
+	}
+	for _, value := range v.RDates {
+		t, err := c.parseTime(value)
+		if err != nil {
+			return err
+		}
+		c.RDates = append(c.RDates, time.Unix(t, 0).UTC())
+	}
+	for _, value := range v.ExDates {
+		t, err := c.parseTime(value)
+		if err != nil {
+			return err
+		}
+		c.ExDates = append(c.ExDates, time.Unix(t, 0).UTC())
+	}
+	if len(v.Overrides) > 0 {
+		c.Overrides = make(map[int64]Event, len(v.Overrides))
+		for value, override := range v.Overrides {
+			recurrenceId, err := c.parseTime(value)
+			if err != nil {
+				return err
+			}
+			c.Overrides[recurrenceId] = override
+		}
+	}
+	return
+}
+
+func (c Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.toJSON())
+}
+
+func (c *Event) UnmarshalJSON(data []byte) error {
+	var v eventJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return c.fromJSON(&v)
+}
+
+// same as Event with recurrenceId, in the encoding of the series
+func (c Occurrence) MarshalJSON() ([]byte, error) {
+	v := c.Event.toJSON()
+	v.RecurrenceId = c.Event.formatTime(c.RecurrenceId)
+	return json.Marshal(v)
+}
+
+func (c *Occurrence) UnmarshalJSON(data []byte) (err error) {
+	var v eventJSON
+	if err = json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	if err = c.Event.fromJSON(&v); err != nil {
+		return
+	}
+	c.RecurrenceId, err = c.Event.parseTime(v.RecurrenceId)
+	return
+}
+
+// EventDecoder reads a JSON array of events one by one, so large arrays are never
+// held in memory at once, CalendarEvent JSON is read as UTC events
+//
+//	decoder := NewEventDecoder(r)
+//	for decoder.Next() {
+//		evt := decoder.Event()
+//	}
+//	if err := decoder.Err(); err != nil {
+//	}
+type EventDecoder struct {
+	dec     *json.Decoder
+	evt     Event
+	err     error
+	started bool
+	done    bool
+}
+
+func NewEventDecoder(r io.Reader) *EventDecoder {
+	return &EventDecoder{dec: json.NewDecoder(r)}
+}
+
+// read the next event, false at the end of the array or on error
+func (c *EventDecoder) Next() bool {
+	if c.err != nil || c.done {
+		return false
+	}
+	if !c.started {
+		c.started = true
+		token, err := c.dec.Token()
+		if err != nil {
+			c.err = err
+			return false
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			c.err = errors.Wrap(errorInvalidJSON, "array of events is expected")
+			return false
+		}
+	}
+	if !c.dec.More() {
+		// consume ] so a truncated array is an error
+		if _, err := c.dec.Token(); err != nil {
+			c.err = err
+		}
+		c.done = true
+		return false
+	}
+	var evt Event
+	if err := c.dec.Decode(&evt); err != nil {
+		c.err = err
+		return false
+	}
+	c.evt = evt
+	return true
+}
+
+func (c *EventDecoder) Event() Event {
+	return c.evt
+}
+
+// the first error, nil when the array was read to the end
+func (c *EventDecoder) Err() error {
+	if c.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return c.err
+}
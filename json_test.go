@@ -0,0 +1,178 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCalendarEventJSON(t *testing.T) {
+	evt := CalendarEvent{3, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC).Unix(), time.Date(2026, 10, 18, 9, 15, 0, 0, time.UTC).Unix()}
+	data, err := json.Marshal(evt)
+	if err != nil || string(data) != `{"id":3,"start":"2026-10-18T09:00:00Z","end":"2026-10-18T09:15:00Z"}` {
+		t.Logf("wrong JSON %s %v", data, err)
+		t.FailNow()
+	}
+	var ret CalendarEvent
+	if err := json.Unmarshal(data, &ret); err != nil || ret != evt {
+		t.Logf("expect %v but got %v %v", evt, ret, err)
+		t.FailNow()
+	}
+	// any offset is fine
+	if err := json.Unmarshal([]byte(`{"id":3,"start":"2026-10-18T05:00:00-04:00","end":"2026-10-18T09:15:00Z"}`), &ret); err != nil || ret != evt {
+		t.Logf("expect %v but got %v %v", evt, ret, err)
+		t.FailNow()
+	}
+	if err := json.Unmarshal([]byte(`{"id":3,"start":"2026-10-18 09:00","end":"2026-10-18T09:15:00Z"}`), &ret); errors.Cause(err) != errorInvalidJSON {
+		t.Logf("expect invalid JSON but got %v", err)
+		t.FailNow()
+	}
+
+	pairs := []CalendarPair{{1, 2}, {3, 7}}
+	data, err = json.Marshal(pairs)
+	if err != nil || string(data) != `[{"first":1,"second":2},{"first":3,"second":7}]` {
+		t.Logf("wrong JSON %s %v", data, err)
+		t.FailNow()
+	}
+	var retPairs []CalendarPair
+	if err := json.Unmarshal(data, &retPairs); err != nil || !reflect.DeepEqual(pairs, retPairs) {
+		t.Logf("expect %v but got %v %v", pairs, retPairs, err)
+		t.FailNow()
+	}
+}
+
+func TestSegmentsJSON(t *testing.T) {
+	segs, pairs := FindSegments(CalendarEvents{{0, 0, 10}, {1, 5, 20}, {2, 30, 40}})
+	expect := Segments{{0, 4, []int{0}}, {5, 10, []int{0, 1}}, {11, 20, []int{1}}, {30, 40, []int{2}}}
+	if !reflect.DeepEqual(segs, expect) || !reflect.DeepEqual(pairs, []CalendarPair{{0, 1}}) {
+		t.Logf("wrong segments %v %v", segs, pairs)
+		t.FailNow()
+	}
+	data, err := json.Marshal(segs)
+	if err != nil || !strings.HasPrefix(string(data), `[{"start":"1970-01-01T00:00:00Z","end":"1970-01-01T00:00:04Z","ids":[0]}`) {
+		t.Logf("wrong JSON %s %v", data, err)
+		t.FailNow()
+	}
+	var ret Segments
+	if err := json.Unmarshal(data, &ret); err != nil || !reflect.DeepEqual(segs, ret) {
+		t.Logf("expect %v but got %v %v", segs, ret, err)
+		t.FailNow()
+	}
+	if data, _ := json.Marshal(Segment{}); !strings.Contains(string(data), `"ids":[]`) {
+		t.Logf("ids should never be null: %s", data)
+		t.FailNow()
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	loadLocation(t, "America/New_York")
+	evts, err := ParseICS(strings.NewReader(testRoundTripICS))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	more, err := ParseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Logf("parse failed: %s", err)
+		t.FailNow()
+	}
+	evts = append(evts, more...)
+
+	data, err := json.Marshal(evts)
+	if err != nil {
+		t.Logf("marshal failed: %s", err)
+		t.FailNow()
+	}
+	for _, expect := range []string{
+		`"start":"2026-10-19T14:00:00","end":"2026-10-19T16:00:00","floating":true`,
+		`"recurrence":"FREQ=WEEKLY;UNTIL=20261130T140000;BYDAY=MO,WE"`,
+		`"start":"2026-11-01","end":"2026-11-03","allDay":true`,
+		`"start":"2026-10-26T09:00:00-04:00","end":"2026-10-26T09:30:00-04:00","zone":"America/New_York"`,
+		`"overrides":{"2026-11-02T09:00:00-05:00":{`,
+		`{"name":"Bob; Jr","email":"bob@example.com","status":"ACCEPTED"}`,
+	} {
+		if !strings.Contains(string(data), expect) {
+			t.Logf("missing %s in %s", expect, data)
+			t.FailNow()
+		}
+	}
+
+	var ret Events
+	if err := json.Unmarshal(data, &ret); err != nil {
+		t.Logf("unmarshal failed: %s", err)
+		t.FailNow()
+	}
+	if !sameImportedEvents(evts, ret) {
+		t.Logf("events changed by JSON:\n%s", data)
+		t.FailNow()
+	}
+	// VTIMEZONE zones are not known by name, so the offset is kept
+	summer := ret[len(ret)-4]
+	if _, offset := time.Unix(summer.Start, 0).In(summer.Zone).Zone(); offset != -4*3600 {
+		t.Logf("wrong offset %d of %s", offset, summer.ToString())
+		t.FailNow()
+	}
+
+	occurrences := ExpandEvents(evts[2:3], 0, 1<<40, time.UTC)
+	data, err = json.Marshal(occurrences)
+	if err != nil || !strings.Contains(string(data), `"recurrenceId":"2026-11-02T09:00:00-05:00"`) {
+		t.Logf("wrong occurrences %s %v", data, err)
+		t.FailNow()
+	}
+	var retOccurrences []Occurrence
+	if err := json.Unmarshal(data, &retOccurrences); err != nil || len(retOccurrences) != len(occurrences) {
+		t.Logf("unmarshal failed: %v", err)
+		t.FailNow()
+	}
+	for i := range occurrences {
+		if retOccurrences[i].RecurrenceId != occurrences[i].RecurrenceId || retOccurrences[i].CalendarEvent != occurrences[i].CalendarEvent {
+			t.Logf("occurrence %d changed", i)
+			t.FailNow()
+		}
+	}
+}
+
+func TestEventDecoder(t *testing.T) {
+	evts := CalendarEvents(testEvents[:100]).Events()
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(evts); err != nil {
+		t.Logf("encode failed: %s", err)
+		t.FailNow()
+	}
+	decoder := NewEventDecoder(bytes.NewReader(b.Bytes()))
+	var ret CalendarEvents
+	for decoder.Next() {
+		evt := decoder.Event()
+		ret = append(ret, evt.CalendarEvent)
+	}
+	if decoder.Err() != nil || !reflect.DeepEqual(ret, CalendarEvents(testEvents[:100])) {
+		t.Logf("decode failed: %v", decoder.Err())
+		t.FailNow()
+	}
+	if decoder.Next() || decoder.Err() != nil {
+		t.Logf("decoder should stay at the end")
+		t.FailNow()
+	}
+
+	// CalendarEvent JSON is read as well
+	decoder = NewEventDecoder(strings.NewReader(`[{"id":1,"start":"2026-10-18T09:00:00Z","end":"2026-10-18T10:00:00Z"}]`))
+	if !decoder.Next() || decoder.Event().Id != 1 || decoder.Next() || decoder.Err() != nil {
+		t.Logf("decode failed: %v", decoder.Err())
+		t.FailNow()
+	}
+
+	for _, input := range []string{`{}`, `[{"id":1,"start":"x","end":"y"}]`, `[{"id":1,"start":"2026-10-18T09:00:00Z","end":"2026-10-18T10:00:00Z"}`} {
+		decoder = NewEventDecoder(strings.NewReader(input))
+		for decoder.Next() {
+		}
+		if decoder.Err() == nil {
+			t.Logf("%s should fail", input)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package calendar
+
+import (
+	"github.com/pkg/errors"
+)
+
+// overlap algorithm used by FindOverlaps
+type Algorithm int
+
+const (
+	AlgorithmSweep Algorithm = iota // default one
+	AlgorithmBrutal
+	AlgorithmSort
+	AlgorithmSeg
+	AlgorithmBucket
+	AlgorithmParallel
+	AlgorithmAuto
+)
+
+var algorithmNames = map[Algorithm]string{
+	AlgorithmSweep:    "sweep",
+	AlgorithmBrutal:   "brutal",
+	AlgorithmSort:     "sort",
+	AlgorithmSeg:      "seg",
+	AlgorithmBucket:   "bucket",
+	AlgorithmParallel: "parallel",
+	AlgorithmAuto:     "auto",
+}
+
+var errorUnknownAlgorithm = errors.New("unknown algorithm")
+var errorUnknownBoundary = errors.New("unknown boundary mode")
+
+func (c Algorithm) String() string {
+	if name, ok := algorithmNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// get the algorithm by the name returned from String
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for algorithm, v := range algorithmNames {
+		if v == name {
+			return algorithm, nil
+		}
+	}
+	return 0, errors.Wrapf(errorUnknownAlgorithm, "%q", name)
+}
+
+// order of the pairs returned by FindOverlaps
+type PairOrder int
+
+const (
+	PairsSorted  PairOrder = iota // default, ordered by FirstId then SecondId
+	PairsAsFound                  // whatever order the algorithm produces, saves sorting
+)
+
+// options of FindOverlaps, zero value means sweep line with sorted output
+type Options struct {
+	Algorithm Algorithm
+	// only for AlgorithmBucket, <= 0 means picking by ChooseBucketSize
+	BucketSize int64
+	// only for AlgorithmParallel, <= 0 means using all cores
+	Workers int
+	// default is PairsSorted
+	Order PairOrder
+	// how the bounds of events are treated, default is closed
+	Boundary BoundaryMode
+	// only used by FindEventOverlaps, default is ignoring all-day events
+	AllDay AllDayPolicy
+}
+
+// find all overlap pairs with FirstId < SecondId, the input is never changed
+// the pairs are ordered by FirstId then SecondId unless Order is PairsAsFound
+func FindOverlaps(evts CalendarEvents, opts Options) (ret []CalendarPair, err error) {
+	finder, err := opts.finder()
+	if err != nil {
+		return
+	}
+	switch opts.Boundary {
+	case BoundaryClosed, BoundaryHalfOpen, BoundaryOpen:
+	default:
+		return nil, errors.Wrapf(errorUnknownBoundary, "%d", int(opts.Boundary))
+	}
+	if opts.Boundary != BoundaryClosed {
+		evts = opts.Boundary.Normalize(evts)
+	}
+	ret = finder.FindOverlapPairs(evts)
+	if opts.Order == PairsSorted {
+		sortPairs(ret)
+	}
+	return
+}
+
+func (c Options) finder() (OverlapFinder, error) {
+	switch c.Algorithm {
+	case AlgorithmSweep:
+		return SweepFinder{}, nil
+	case AlgorithmBrutal:
+		return BrutalFinder{}, nil
+	case AlgorithmSort:
+		return SortFinder{}, nil
+	case AlgorithmSeg:
+		return SegFinder{}, nil
+	case AlgorithmBucket:
+		return BucketFinder{Size: c.BucketSize}, nil
+	case AlgorithmParallel:
+		return ParallelFinder{Workers: c.Workers}, nil
+	case AlgorithmAuto:
+		return AutoFinder{}, nil
+	}
+	return nil, errors.Wrapf(errorUnknownAlgorithm, "%d", int(c.Algorithm))
+}
@@ -0,0 +1,78 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseAlgorithm(t *testing.T) {
+	for algorithm := AlgorithmSweep; algorithm <= AlgorithmAuto; algorithm++ {
+		if ret, err := ParseAlgorithm(algorithm.String()); err != nil || ret != algorithm {
+			t.Logf("parse %s should return itself instead of %s, %v", algorithm, ret, err)
+			t.FailNow()
+		}
+	}
+	if _, err := ParseAlgorithm("quantum"); err == nil {
+		t.Log("unknown algorithm should fail")
+		t.FailNow()
+	}
+}
+
+func TestFindOverlaps(t *testing.T) {
+	// reversed ids make every algorithm produce a different raw order
+	events := make(CalendarEvents, len(testEvents))
+	for i, evt := range testEvents {
+		evt.Id = len(testEvents) - i
+		events[i] = evt
+	}
+	original := make(CalendarEvents, len(events))
+	copy(original, events)
+
+	var expected []CalendarPair
+	for algorithm := AlgorithmSweep; algorithm <= AlgorithmAuto; algorithm++ {
+		ret, err := FindOverlaps(events, Options{Algorithm: algorithm, BucketSize: 100, Workers: 4})
+		if err != nil {
+			t.Logf("%s failed: %s", algorithm, err)
+			t.FailNow()
+		}
+		if !reflect.DeepEqual(events, original) {
+			t.Logf("%s changed the input", algorithm)
+			t.FailNow()
+		}
+		for i := 1; i < len(ret); i++ {
+			if ret[i-1].FirstId > ret[i].FirstId ||
+				ret[i-1].FirstId == ret[i].FirstId && ret[i-1].SecondId >= ret[i].SecondId {
+				t.Logf("%s pairs %v, %v are not sorted", algorithm, ret[i-1], ret[i])
+				t.FailNow()
+			}
+		}
+		if expected == nil {
+			expected = ret
+		} else if !reflect.DeepEqual(ret, expected) {
+			t.Logf("%s is different from %s", algorithm, AlgorithmSweep)
+			t.FailNow()
+		}
+	}
+
+	if ret, _ := FindOverlaps(events, Options{Order: PairsAsFound}); !isSamePairs(ret, expected) {
+		t.Log("pairs as found should be the same pairs")
+		t.FailNow()
+	}
+	if FindOverlapPairsSort(events); !reflect.DeepEqual(events, original) {
+		t.Log("sort changed the input")
+		t.FailNow()
+	}
+
+	if _, err := FindOverlaps(events, Options{Algorithm: Algorithm(100)}); err == nil {
+		t.Log("unknown algorithm should fail")
+		t.FailNow()
+	}
+	for _, mode := range []BoundaryMode{-1, BoundaryOpen + 1} {
+		if _, err := FindOverlaps(events, Options{Boundary: mode}); errors.Cause(err) != errorUnknownBoundary {
+			t.Logf("boundary mode %d should fail instead of %v", int(mode), err)
+			t.FailNow()
+		}
+	}
+}
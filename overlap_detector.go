@@ -0,0 +1,103 @@
+package calendar
+
+import "sort"
+
+// OverlapDetector keeps the events and their current conflicts between calls
+// so adding or moving one event only costs O(log n + k) instead of recomputing everything
+type OverlapDetector struct {
+	tree *IntervalTree
+	// event id to ids of events it currently overlaps with
+	conflicts map[int]map[int]bool
+}
+
+func NewOverlapDetector() *OverlapDetector {
+	return NewOverlapDetectorMode(BoundaryClosed)
+}
+
+// same as NewOverlapDetector but events are treated in given boundary mode
+func NewOverlapDetectorMode(mode BoundaryMode) *OverlapDetector {
+	return &OverlapDetector{
+		tree:      NewIntervalTreeMode(nil, mode),
+		conflicts: make(map[int]map[int]bool),
+	}
+}
+
+// add the event and return the conflicts it introduces, FirstId always smaller
+// adding an event with existing id moves it, only pairs which did not exist before are returned
+func (c *OverlapDetector) Add(evt CalendarEvent) (ret []CalendarPair) {
+	previous := c.conflicts[evt.Id]
+	c.Remove(evt.Id)
+
+	current := make(map[int]bool)
+	for _, other := range c.tree.Overlapping(evt.Start, evt.End) {
+		current[other.Id] = true
+		c.link(other.Id, evt.Id)
+		if !previous[other.Id] {
+			ret = append(ret, newCalendarPair(evt.Id, other.Id))
+		}
+	}
+	c.conflicts[evt.Id] = current
+	c.tree.Insert(evt)
+	return
+}
+
+// remove the event and all conflicts related to it
+func (c *OverlapDetector) Remove(id int) {
+	if !c.tree.Delete(id) {
+		return
+	}
+	for other := range c.conflicts[id] {
+		delete(c.conflicts[other], id)
+	}
+	delete(c.conflicts, id)
+}
+
+// return all current conflicts ordered by FirstId then SecondId
+func (c *OverlapDetector) Pairs() (ret []CalendarPair) {
+	for id, others := range c.conflicts {
+		for other := range others {
+			if id < other {
+				ret = append(ret, CalendarPair{id, other})
+			}
+		}
+	}
+	sortPairs(ret)
+	return
+}
+
+// return the ids of events currently overlaps with given id
+func (c *OverlapDetector) Conflicts(id int) (ret []int) {
+	for other := range c.conflicts[id] {
+		ret = append(ret, other)
+	}
+	sort.Ints(ret)
+	return
+}
+
+func (c *OverlapDetector) Len() int {
+	return c.tree.Len()
+}
+
+func (c *OverlapDetector) link(first, second int) {
+	if _, ok := c.conflicts[first]; !ok {
+		c.conflicts[first] = make(map[int]bool)
+	}
+	c.conflicts[first][second] = true
+}
+
+// make sure firstId always smaller
+func newCalendarPair(first, second int) CalendarPair {
+	if first <= second {
+		return CalendarPair{first, second}
+	}
+	return CalendarPair{second, first}
+}
+
+func sortPairs(pairs []CalendarPair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].FirstId != pairs[j].FirstId {
+			return pairs[i].FirstId < pairs[j].FirstId
+		}
+		return pairs[i].SecondId < pairs[j].SecondId
+	})
+}
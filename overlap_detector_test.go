@@ -0,0 +1,73 @@
+package calendar
+
+import (
+	"testing"
+)
+
+func TestOverlapDetector_Add(t *testing.T) {
+	detector := NewOverlapDetector()
+	if ret := detector.Add(CalendarEvent{0, 0, 100}); len(ret) != 0 {
+		t.Logf("first event should has no conflict: %v", ret)
+		t.FailNow()
+	}
+	if ret := detector.Add(CalendarEvent{2, 100, 200}); len(ret) != 1 || ret[0] != (CalendarPair{0, 2}) {
+		t.Logf("it should return pair 0, 2 instead of %v", ret)
+		t.FailNow()
+	}
+	if ret := detector.Add(CalendarEvent{1, 150, 300}); len(ret) != 1 || ret[0] != (CalendarPair{1, 2}) {
+		t.Logf("it should return pair 1, 2 instead of %v", ret)
+		t.FailNow()
+	}
+
+	// drag event 1 so it also overlaps event 0, only the new pair is reported
+	if ret := detector.Add(CalendarEvent{1, 50, 300}); len(ret) != 1 || ret[0] != (CalendarPair{0, 1}) {
+		t.Logf("it should return pair 0, 1 instead of %v", ret)
+		t.FailNow()
+	}
+	// drag event 1 away from everything
+	if ret := detector.Add(CalendarEvent{1, 500, 600}); len(ret) != 0 {
+		t.Logf("it should has no new conflict: %v", ret)
+		t.FailNow()
+	}
+	if ret := detector.Pairs(); len(ret) != 1 || ret[0] != (CalendarPair{0, 2}) {
+		t.Logf("it should only have pair 0, 2 instead of %v", ret)
+		t.FailNow()
+	}
+
+	detector.Remove(0)
+	if ret := detector.Pairs(); len(ret) != 0 {
+		t.Logf("it should has no conflict: %v", ret)
+		t.FailNow()
+	}
+	if detector.Len() != 2 {
+		t.Logf("it should have 2 events instead of %d", detector.Len())
+		t.FailNow()
+	}
+}
+
+func TestOverlapDetector_Pairs(t *testing.T) {
+	detector := NewOverlapDetector()
+	total := 0
+	for _, evt := range testEvents {
+		total += len(detector.Add(evt))
+	}
+	ret := detector.Pairs()
+	if total != len(ret) {
+		t.Logf("added conflicts %d is different from pairs %d", total, len(ret))
+		t.FailNow()
+	}
+	baseline := FindOverlapPairsBrutal(testEvents)
+	if isSame, leftNotFound, rightNotFound := compareTwoPairArrays(ret, baseline); !isSame {
+		t.Logf("detector pairs are different from baseline: %v, %v", leftNotFound, rightNotFound)
+		t.FailNow()
+	}
+
+	// removing every event should leave no conflict behind
+	for _, evt := range testEvents {
+		detector.Remove(evt.Id)
+	}
+	if len(detector.Pairs()) != 0 || detector.Len() != 0 {
+		t.Log("detector should be empty")
+		t.FailNow()
+	}
+}
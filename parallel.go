@@ -0,0 +1,109 @@
+package calendar
+
+import (
+	"container/heap"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// index pair into the input events, first is always the smaller index
+type indexPair struct {
+	first  int
+	second int
+}
+
+// the timeline is cut into partitions of events sorted by Start, each worker sweeps one partition
+// every pair belongs to the partition of the event starting later, events crossing the partition
+// boundary are put into the active set at the beginning, so no pair is found twice
+// workers <= 0 means using all cores
+// the output is identical to FindOverlapPairsBrutal, including the order
+func FindOverlapPairsParallel(evts CalendarEvents, workers int) (ret []CalendarPair) {
+	length := len(evts)
+
+	// if the input is less then 2 events then there must be no overlaps
+	if length < 2 {
+		return
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > length {
+		workers = length
+	}
+
+	// sort the indices instead of the events so the input stays untouched
+	order := make([]int, length)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return evts[order[i]].Start < evts[order[j]].Start
+	})
+
+	results := make([][]indexPair, workers)
+	chunk := (length + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo := w * chunk
+		hi := lo + chunk
+		if hi > length {
+			hi = length
+		}
+		if lo >= hi {
+			break
+		}
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			results[w] = sweepPartition(evts, order, lo, hi)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	var pairs []indexPair
+	for _, result := range results {
+		pairs = append(pairs, result...)
+	}
+	// same order as the brutal nested loops
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].first != pairs[j].first {
+			return pairs[i].first < pairs[j].first
+		}
+		return pairs[i].second < pairs[j].second
+	})
+	ret = make([]CalendarPair, len(pairs))
+	for i, pair := range pairs {
+		ret[i] = newCalendarPair(evts[pair.first].Id, evts[pair.second].Id)
+	}
+	return
+}
+
+// find the pairs whose later event is within order[lo:hi]
+func sweepPartition(evts CalendarEvents, order []int, lo, hi int) (ret []indexPair) {
+	// the Id of active events is used to store the index of input
+	var active endHeap
+	boundary := evts[order[lo]].Start
+	for _, idx := range order[:lo] {
+		if evts[idx].End >= boundary {
+			active = append(active, CalendarEvent{idx, evts[idx].Start, evts[idx].End})
+		}
+	}
+	heap.Init(&active)
+
+	for _, idx := range order[lo:hi] {
+		evt := evts[idx]
+		for len(active) > 0 && active[0].End < evt.Start {
+			heap.Pop(&active)
+		}
+		for _, other := range active {
+			if other.Id < idx {
+				ret = append(ret, indexPair{other.Id, idx})
+			} else {
+				ret = append(ret, indexPair{idx, other.Id})
+			}
+		}
+		heap.Push(&active, CalendarEvent{idx, evt.Start, evt.End})
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindOverlapPairsParallel(t *testing.T) {
+	events := []CalendarEvent{{0, 0, 100}, {1, 101, 200}, {2, 0, 2000}, {3, 101, 200}}
+	ret := FindOverlapPairsParallel(events, 2)
+	if baseline := FindOverlapPairsBrutal(events); !reflect.DeepEqual(ret, baseline) {
+		t.Logf("parallel %v should be same as brutal %v", ret, baseline)
+		t.FailNow()
+	}
+
+	events = make([]CalendarEvent, len(testEvents))
+	copy(events, testEvents)
+	baseline := FindOverlapPairsBrutal(events)
+	for _, workers := range []int{0, 1, 3, 8, 499, 1000} {
+		ret = FindOverlapPairsParallel(events, workers)
+		if !reflect.DeepEqual(ret, baseline) {
+			t.Logf("parallel with %d workers is different from brutal", workers)
+			t.FailNow()
+		}
+	}
+	if !reflect.DeepEqual(events, testEvents) {
+		t.Log("input events should not be changed")
+		t.FailNow()
+	}
+}
+
+func Benchmark_parallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindOverlapPairsParallel(testEvents, 0)
+	}
+}
@@ -0,0 +1,700 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Frequency int
+
+const (
+	FreqDaily Frequency = iota
+	FreqWeekly
+	FreqMonthly
+	FreqYearly
+)
+
+var frequencyNames = map[Frequency]string{
+	FreqDaily:   "DAILY",
+	FreqWeekly:  "WEEKLY",
+	FreqMonthly: "MONTHLY",
+	FreqYearly:  "YEARLY",
+}
+
+func (c Frequency) String() string {
+	return frequencyNames[c]
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Sunday:    "SU",
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+}
+
+// one BYDAY value, e.g. MO is {0, Monday}, -1FR is {-1, Friday} the last Friday
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+func (c WeekdayNum) String() string {
+	if c.N == 0 {
+		return weekdayNames[c.Weekday]
+	}
+	return strconv.Itoa(c.N) + weekdayNames[c.Weekday]
+}
+
+// RecurrenceRule is the RRULE of RFC 5545, HOURLY and more frequent rules are not supported
+type RecurrenceRule struct {
+	Freq Frequency
+	// 0 means 1
+	Interval int
+	// max number of occurrences including the first one, 0 means no limit
+	Count int
+	// last possible start, zero means no limit
+	// same encoding as Event.Start, so it is wall clock in UTC for floating and all-day events
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	// first day of week, only matters for WEEKLY rules with INTERVAL > 1
+	// ParseRecurrenceRule defaults it to Monday as RFC 5545 does
+	WeekStart time.Weekday
+}
+
+var errorInvalidRule = errors.New("invalid recurrence rule")
+
+// give up when periods produce nothing for this many years, e.g. BYMONTH=2;BYMONTHDAY=30
+// long enough for February 29 on a weekday, which may be 40 years apart around 2100
+const maxEmptyYears = 100
+
+// parse the value of RRULE, e.g. FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1
+func ParseRecurrenceRule(value string) (*RecurrenceRule, error) {
+	rule := &RecurrenceRule{WeekStart: time.Monday}
+	hasFreq := false
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Wrapf(errorInvalidRule, "%q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), kv[1]
+		var err error
+		switch key {
+		case "FREQ":
+			hasFreq = false
+			for freq, name := range frequencyNames {
+				if strings.EqualFold(name, val) {
+					rule.Freq = freq
+					hasFreq = true
+				}
+			}
+			if !hasFreq {
+				err = errors.Errorf("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = errors.Errorf("INTERVAL %d should be positive", rule.Interval)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+			if err == nil && rule.Count < 1 {
+				err = errors.Errorf("COUNT %d should be positive", rule.Count)
+			}
+		case "UNTIL":
+			rule.Until, err = parseRuleTime(val)
+		case "BYDAY":
+			for _, v := range strings.Split(val, ",") {
+				var day WeekdayNum
+				if day, err = parseWeekdayNum(v); err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(val, 1, 31)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseInts(val, 1, 366)
+		case "BYMONTH":
+			var months []int
+			if months, err = parseInts(val, 1, 12); err == nil {
+				for _, month := range months {
+					if month < 0 {
+						err = errors.Errorf("BYMONTH %d should be positive", month)
+						break
+					}
+					rule.ByMonth = append(rule.ByMonth, time.Month(month))
+				}
+			}
+		case "WKST":
+			var day WeekdayNum
+			if day, err = parseWeekdayNum(val); err == nil {
+				rule.WeekStart = day.Weekday
+			}
+		default:
+			err = errors.Errorf("unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errorInvalidRule, "%s: %s", part, err)
+		}
+	}
+	if !hasFreq {
+		return nil, errors.Wrap(errorInvalidRule, "FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.Wrap(errorInvalidRule, "COUNT and UNTIL can not be used together")
+	}
+	return rule, nil
+}
+
+// times without Z are wall clock, kept as if in UTC
+func parseRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid time %q", value)
+}
+
+func parseWeekdayNum(value string) (ret WeekdayNum, err error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		err = errors.Errorf("invalid weekday %q", value)
+		return
+	}
+	name := value[len(value)-2:]
+	found := false
+	for weekday, v := range weekdayNames {
+		if v == name {
+			ret.Weekday = weekday
+			found = true
+		}
+	}
+	if !found {
+		err = errors.Errorf("invalid weekday %q", value)
+		return
+	}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		if ret.N, err = strconv.Atoi(prefix); err != nil || ret.N == 0 || ret.N > 53 || ret.N < -53 {
+			err = errors.Errorf("invalid weekday %q", value)
+		}
+	}
+	return
+}
+
+// parse comma separated ints, each one should be within [-max, -min] or [min, max]
+func parseInts(value string, min, max int) (ret []int, err error) {
+	for _, v := range strings.Split(value, ",") {
+		var n int
+		if n, err = strconv.Atoi(strings.TrimSpace(v)); err != nil {
+			return nil, err
+		}
+		if n < 0 && (-n < min || -n > max) || n >= 0 && (n < min || n > max) {
+			return nil, errors.Errorf("%d out of range", n)
+		}
+		ret = append(ret, n)
+	}
+	return
+}
+
+// the RRULE value, same format ParseRecurrenceRule accepts
+func (c *RecurrenceRule) String() string {
+	return c.format("20060102T150405Z")
+}
+
+// format with UNTIL in layout, UNTIL of floating and all-day events is wall clock
+func (c *RecurrenceRule) format(untilLayout string) string {
+	parts := []string{"FREQ=" + c.Freq.String()}
+	if c.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", c.Interval))
+	}
+	if c.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", c.Count))
+	}
+	if !c.Until.IsZero() {
+		parts = append(parts, "UNTIL="+c.Until.UTC().Format(untilLayout))
+	}
+	if len(c.ByDay) > 0 {
+		days := make([]string, len(c.ByDay))
+		for i, day := range c.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(c.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(c.ByMonthDay))
+	}
+	if len(c.ByMonth) > 0 {
+		months := make([]int, len(c.ByMonth))
+		for i, month := range c.ByMonth {
+			months[i] = int(month)
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if len(c.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(c.BySetPos))
+	}
+	if c.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[c.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	ret := make([]string, len(values))
+	for i, v := range values {
+		ret[i] = strconv.Itoa(v)
+	}
+	return strings.Join(ret, ",")
+}
+
+// call fn with the wall clock start of every occurrence in order, dtstart is wall clock in UTC
+// stop when fn returns false, COUNT and UNTIL are handled by the caller
+func (c *RecurrenceRule) each(dtstart time.Time, fn func(wall time.Time) bool) {
+	interval := c.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	year, month, day := dtstart.Date()
+	hour, minute, second := dtstart.Clock()
+	first := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	// the first day of the period containing dtstart
+	var periodStart time.Time
+	switch c.Freq {
+	case FreqDaily:
+		periodStart = first
+	case FreqWeekly:
+		periodStart = first.AddDate(0, 0, -((int(first.Weekday()) - int(c.WeekStart) + 7) % 7))
+	case FreqMonthly:
+		periodStart = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case FreqYearly:
+		periodStart = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	giveUp := periodStart.AddDate(maxEmptyYears, 0, 0)
+	for k := 0; ; k++ {
+		var start time.Time
+		var days []time.Time
+		switch c.Freq {
+		case FreqDaily:
+			start = periodStart.AddDate(0, 0, k*interval)
+			days = c.filterDays([]time.Time{start})
+		case FreqWeekly:
+			start = periodStart.AddDate(0, 0, 7*k*interval)
+			days = c.weekDays(start, dtstart)
+		case FreqMonthly:
+			start = periodStart.AddDate(0, k*interval, 0)
+			days = c.monthDays(start, dtstart)
+		case FreqYearly:
+			start = periodStart.AddDate(k*interval, 0, 0)
+			days = c.yearDays(start, dtstart)
+		}
+		days = c.setPos(days)
+		if len(days) == 0 {
+			if start.After(giveUp) {
+				return
+			}
+			continue
+		}
+		giveUp = start.AddDate(maxEmptyYears, 0, 0)
+		for _, d := range days {
+			wall := time.Date(d.Year(), d.Month(), d.Day(), hour, minute, second, 0, time.UTC)
+			if wall.Before(dtstart) {
+				continue
+			}
+			if !fn(wall) {
+				return
+			}
+		}
+	}
+}
+
+// days after which the starts of the rule repeat by wall clock, 0 when they do not repeat
+// within weeks, as for MONTHLY and YEARLY rules or ones limited by BYMONTH or BYMONTHDAY
+func (c *RecurrenceRule) periodDays() int64 {
+	interval := int64(c.Interval)
+	if interval < 1 {
+		interval = 1
+	}
+	if len(c.ByMonth) > 0 || len(c.ByMonthDay) > 0 {
+		return 0
+	}
+	switch c.Freq {
+	case FreqDaily:
+		if len(c.ByDay) > 0 {
+			// the days of the interval falling on the weekdays repeat every 7 intervals at most
+			return interval / gcd(interval, 7) * 7
+		}
+		return interval
+	case FreqWeekly:
+		return 7 * interval
+	}
+	return 0
+}
+
+func (c *RecurrenceRule) matchMonth(d time.Time) bool {
+	if len(c.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range c.ByMonth {
+		if d.Month() == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *RecurrenceRule) matchMonthDay(d time.Time) bool {
+	if len(c.ByMonthDay) == 0 {
+		return true
+	}
+	last := daysIn(d.Year(), d.Month())
+	for _, v := range c.ByMonthDay {
+		if v == d.Day() || v < 0 && last+v+1 == d.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// scope is the days of month or year the nth weekday counts in
+func (c *RecurrenceRule) matchDay(d time.Time, scope []time.Time) bool {
+	if len(c.ByDay) == 0 {
+		return true
+	}
+	for _, day := range c.ByDay {
+		if day.Weekday != d.Weekday() {
+			continue
+		}
+		if day.N == 0 {
+			return true
+		}
+		var same []time.Time
+		for _, s := range scope {
+			if s.Weekday() == day.Weekday {
+				same = append(same, s)
+			}
+		}
+		idx := day.N - 1
+		if day.N < 0 {
+			idx = len(same) + day.N
+		}
+		if idx >= 0 && idx < len(same) && same[idx].Equal(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// used by DAILY rules, nth weekday does not make sense here so it is treated as every weekday
+func (c *RecurrenceRule) filterDays(days []time.Time) (ret []time.Time) {
+	for _, d := range days {
+		if c.matchMonth(d) && c.matchMonthDay(d) && c.matchDay(d, []time.Time{d}) {
+			ret = append(ret, d)
+		}
+	}
+	return
+}
+
+func (c *RecurrenceRule) weekDays(weekStart, dtstart time.Time) (ret []time.Time) {
+	for i := 0; i < 7; i++ {
+		d := weekStart.AddDate(0, 0, i)
+		if !c.matchMonth(d) {
+			continue
+		}
+		if len(c.ByDay) == 0 {
+			if d.Weekday() == dtstart.Weekday() {
+				ret = append(ret, d)
+			}
+		} else if c.matchDay(d, []time.Time{d}) {
+			ret = append(ret, d)
+		}
+	}
+	return
+}
+
+func (c *RecurrenceRule) monthDays(monthStart, dtstart time.Time) (ret []time.Time) {
+	if !c.matchMonth(monthStart) {
+		return
+	}
+	scope := make([]time.Time, daysIn(monthStart.Year(), monthStart.Month()))
+	for i := range scope {
+		scope[i] = monthStart.AddDate(0, 0, i)
+	}
+	for _, d := range scope {
+		if len(c.ByDay) == 0 && len(c.ByMonthDay) == 0 {
+			if d.Day() == dtstart.Day() {
+				ret = append(ret, d)
+			}
+		} else if c.matchMonthDay(d) && c.matchDay(d, scope) {
+			ret = append(ret, d)
+		}
+	}
+	return
+}
+
+func (c *RecurrenceRule) yearDays(yearStart, dtstart time.Time) (ret []time.Time) {
+	// nth weekday of the whole year, e.g. 20MO
+	if len(c.ByDay) > 0 && len(c.ByMonth) == 0 && len(c.ByMonthDay) == 0 {
+		var scope []time.Time
+		for d := yearStart; d.Year() == yearStart.Year(); d = d.AddDate(0, 0, 1) {
+			scope = append(scope, d)
+		}
+		for _, d := range scope {
+			if c.matchDay(d, scope) {
+				ret = append(ret, d)
+			}
+		}
+		return
+	}
+	months := c.ByMonth
+	if len(months) == 0 && len(c.ByMonthDay) > 0 {
+		// BYMONTHDAY expands over the whole year
+		months = []time.Month{time.January, time.February, time.March, time.April, time.May, time.June,
+			time.July, time.August, time.September, time.October, time.November, time.December}
+	} else if len(months) == 0 {
+		months = []time.Month{dtstart.Month()}
+	}
+	sorted := make([]int, len(months))
+	for i, month := range months {
+		sorted[i] = int(month)
+	}
+	sort.Ints(sorted)
+	for _, month := range sorted {
+		ret = append(ret, c.monthDays(time.Date(yearStart.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC), dtstart)...)
+	}
+	return
+}
+
+func (c *RecurrenceRule) setPos(days []time.Time) []time.Time {
+	if len(c.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var ret []time.Time
+	picked := make(map[int]bool)
+	for _, pos := range c.BySetPos {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(days) + pos
+		}
+		if idx >= 0 && idx < len(days) && !picked[idx] {
+			picked[idx] = true
+			ret = append(ret, days[idx])
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Before(ret[j])
+	})
+	return ret
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// one concrete instance of an event
+type Occurrence struct {
+	// Recurrence, RDates, ExDates and Overrides are cleared, Id is still the id of the series
+	// overridden instances have the times and fields of the override
+	Event
+	// original start of this instance, same encoding as Start
+	RecurrenceId int64
+}
+
+// reported by FindOccurrenceOverlaps, saying which instances conflict
+type OccurrencePair struct {
+	First  Occurrence
+	Second Occurrence
+}
+
+// occurrences of the event overlapping [windowStart, windowEnd] for a user in loc
+// events without Recurrence and RDates only have themselves
+func (c *Event) Occurrences(windowStart, windowEnd int64, loc *time.Location) (ret []Occurrence) {
+	// an instance after the window may be moved into it
+	limit := windowEnd
+	for recurrenceId := range c.Overrides {
+		if recurrenceId > limit {
+			limit = recurrenceId
+		}
+	}
+	for _, start := range c.occurrenceStarts(limit) {
+		occ := c.occurrence(start)
+		instants := occ.Instants(loc)
+		if instants.End >= windowStart && instants.Start <= windowEnd {
+			ret = append(ret, occ)
+		}
+	}
+	return
+}
+
+// check an occurrence overlaps [windowStart, windowEnd] for a user in loc, same as
+// len(Occurrences) > 0 but it stops at the first one instead of expanding the whole window
+func (c *Event) occursWithin(windowStart, windowEnd int64, loc *time.Location) bool {
+	overlaps := func(start int64) bool {
+		occ := c.occurrence(start)
+		instants := occ.Instants(loc)
+		return instants.End >= windowStart && instants.Start <= windowEnd
+	}
+	// an instance after the window may be moved into it
+	for recurrenceId := range c.Overrides {
+		if recurrenceId > windowEnd && overlaps(recurrenceId) && c.hasOccurrence(recurrenceId) {
+			return true
+		}
+	}
+	found := false
+	c.eachStart(windowEnd, func(start int64) bool {
+		found = overlaps(start)
+		return !found
+	})
+	return found
+}
+
+// the instance originally starting at start, with the override of it if there is one
+func (c *Event) occurrence(start int64) Occurrence {
+	occ := Occurrence{Event: *c, RecurrenceId: start}
+	if override, ok := c.Overrides[start]; ok {
+		occ.Event = override
+		occ.Id = c.Id
+	} else {
+		occ.CalendarEvent = CalendarEvent{c.Id, start, start + c.End - c.Start}
+	}
+	occ.Recurrence = nil
+	occ.RDates = nil
+	occ.ExDates = nil
+	occ.Overrides = nil
+	return occ
+}
+
+// starts of all occurrences which may start before limit, in the encoding of Start
+func (c *Event) occurrenceStarts(limit int64) (ret []int64) {
+	seen := make(map[int64]bool)
+	c.eachStart(limit, func(start int64) bool {
+		if !seen[start] {
+			seen[start] = true
+			ret = append(ret, start)
+		}
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return
+}
+
+// call fn with the starts of occurrenceStarts, RDATEs first then the rule in order, a start
+// in both comes twice, stop when fn returns false
+func (c *Event) eachStart(limit int64, fn func(start int64) bool) {
+	if c.Floating || c.AllDay {
+		// wall clock in UTC can be off by up to 14 hours from the real instant
+		limit += 26 * 3600
+	}
+	excluded := make(map[int64]bool, len(c.ExDates))
+	for _, date := range c.ExDates {
+		excluded[date.Unix()] = true
+	}
+	for _, date := range c.RDates {
+		if start := date.Unix(); !excluded[start] && start <= limit && !fn(start) {
+			return
+		}
+	}
+	c.eachRuleStart(limit, func(start int64) bool {
+		// only DTSTART may be after limit, the rule starts after it
+		if start > limit {
+			return false
+		}
+		return excluded[start] || fn(start)
+	})
+}
+
+// starts generated by DTSTART and the rule up to limit in order, RDATE and EXDATE are not applied
+func (c *Event) ruleStarts(limit int64) (ret []int64) {
+	c.eachRuleStart(limit, func(start int64) bool {
+		ret = append(ret, start)
+		return true
+	})
+	return
+}
+
+// call fn with every start of ruleStarts in order, stop when fn returns false
+func (c *Event) eachRuleStart(limit int64, fn func(start int64) bool) {
+	// DTSTART is always the first occurrence
+	if !fn(c.Start) || c.Recurrence == nil {
+		return
+	}
+	wallClock := c.Floating || c.AllDay
+	zone := c.zone()
+	if wallClock {
+		zone = time.UTC
+	}
+	dtstart := time.Unix(c.Start, 0).In(zone)
+	dtstart = time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, time.UTC)
+	count := 1
+	until := int64(0)
+	if !c.Recurrence.Until.IsZero() {
+		until = c.Recurrence.Until.Unix()
+	}
+	c.Recurrence.each(dtstart, func(wall time.Time) bool {
+		start := wall.Unix()
+		if !wallClock {
+			start = WallClockIn(wall, zone).Unix()
+		}
+		if start == c.Start {
+			return true
+		}
+		if c.Recurrence.Count > 0 && count >= c.Recurrence.Count || until != 0 && start > until || start > limit {
+			return false
+		}
+		count++
+		return fn(start)
+	})
+}
+
+// all occurrences of all events overlapping [windowStart, windowEnd] for a user in loc
+func ExpandEvents(evts Events, windowStart, windowEnd int64, loc *time.Location) (ret []Occurrence) {
+	for i := range evts {
+		ret = append(ret, evts[i].Occurrences(windowStart, windowEnd, loc)...)
+	}
+	return
+}
+
+// expand the events within the window and find conflicts between the occurrences
+// the all-day policy and algorithm are taken from opts, same as FindEventOverlaps
+func FindOccurrenceOverlaps(evts Events, windowStart, windowEnd int64, loc *time.Location, opts Options) ([]OccurrencePair, error) {
+	occurrences := ExpandEvents(evts, windowStart, windowEnd, loc)
+	// the index of occurrence is used as id, so the finders see unique ids
+	var instants CalendarEvents
+	var index []int
+	for i := range occurrences {
+		if occurrences[i].AllDay && opts.AllDay != AllDayConflict {
+			continue
+		}
+		instant := occurrences[i].Instants(loc)
+		instant.Id = len(index)
+		instants = append(instants, instant)
+		index = append(index, i)
+	}
+	pairs, err := FindOverlaps(instants, opts)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]OccurrencePair, len(pairs))
+	for i, pair := range pairs {
+		ret[i] = OccurrencePair{occurrences[index[pair.FirstId]], occurrences[index[pair.SecondId]]}
+	}
+	return ret, nil
+}
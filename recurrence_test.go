@@ -0,0 +1,230 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseRule(t *testing.T, value string) *RecurrenceRule {
+	rule, err := ParseRecurrenceRule(value)
+	if err != nil {
+		t.Logf("parse %s failed: %s", value, err)
+		t.FailNow()
+	}
+	return rule
+}
+
+func occurrenceDates(occurrences []Occurrence, loc *time.Location) (ret []string) {
+	for _, occ := range occurrences {
+		ret = append(ret, occ.StartTime(loc).Format("2006-01-02 15:04"))
+	}
+	return
+}
+
+func sameStrings(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if left[i] != right[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	for _, value := range []string{
+		"FREQ=WEEKLY;INTERVAL=2;COUNT=10;BYDAY=MO,WE",
+		"FREQ=MONTHLY;BYDAY=-1FR",
+		"FREQ=MONTHLY;UNTIL=20261231T235959Z;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"FREQ=YEARLY;BYMONTHDAY=1,-1;BYMONTH=1,7;WKST=SU",
+	} {
+		if ret := mustParseRule(t, value).String(); ret != value {
+			t.Logf("%s should be formatted back instead of %s", value, ret)
+			t.FailNow()
+		}
+	}
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=SECONDLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20261231",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=-1",
+		"FREQ=DAILY;FOO=1",
+		"FREQ",
+	} {
+		if _, err := ParseRecurrenceRule(value); err == nil {
+			t.Logf("%q should be invalid", value)
+			t.FailNow()
+		}
+	}
+}
+
+func TestEvent_Occurrences(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	window := func(from, to string) (int64, int64) {
+		start, _ := time.ParseInLocation("2006-01-02", from, newYork)
+		end, _ := time.ParseInLocation("2006-01-02", to, newYork)
+		return start.Unix(), end.Unix()
+	}
+	newEvent := func(start string, rule string) Event {
+		dtstart, _ := time.ParseInLocation("2006-01-02 15:04", start, newYork)
+		evt := NewZonedEvent(0, dtstart, dtstart.Add(30*time.Minute))
+		evt.Recurrence = mustParseRule(t, rule)
+		return evt
+	}
+
+	cases := []struct {
+		name     string
+		evt      Event
+		from, to string
+		expected []string
+	}{
+		{
+			"weekly standup keeps 9:00 across DST",
+			newEvent("2026-10-26 09:00", "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4"),
+			"2026-01-01", "2027-01-01",
+			[]string{"2026-10-26 09:00", "2026-10-28 09:00", "2026-11-02 09:00", "2026-11-04 09:00"},
+		},
+		{
+			"last friday of the month",
+			newEvent("2026-10-30 15:00", "FREQ=MONTHLY;BYDAY=-1FR"),
+			"2026-10-01", "2027-02-01",
+			[]string{"2026-10-30 15:00", "2026-11-27 15:00", "2026-12-25 15:00", "2027-01-29 15:00"},
+		},
+		{
+			"last work day of the month",
+			newEvent("2026-10-30 10:00", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;UNTIL=20270201T000000Z"),
+			"2026-10-01", "2028-01-01",
+			[]string{"2026-10-30 10:00", "2026-11-30 10:00", "2026-12-31 10:00", "2027-01-29 10:00"},
+		},
+		{
+			"monthly review on 31st skips short months",
+			newEvent("2026-10-31 11:00", "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3"),
+			"2026-10-01", "2028-01-01",
+			[]string{"2026-10-31 11:00", "2026-12-31 11:00", "2027-01-31 11:00"},
+		},
+		{
+			"every other week within window",
+			newEvent("2026-01-05 09:00", "FREQ=WEEKLY;INTERVAL=2"),
+			"2026-10-18", "2026-11-10",
+			[]string{"2026-10-26 09:00", "2026-11-09 09:00"},
+		},
+		{
+			"thanksgiving",
+			newEvent("2026-11-26 12:00", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH"),
+			"2026-01-01", "2029-01-01",
+			[]string{"2026-11-26 12:00", "2027-11-25 12:00", "2028-11-23 12:00"},
+		},
+		{
+			// the RFC 5545 example of every Friday the 13th, by year instead of by month
+			"friday the 13th",
+			newEvent("1997-09-02 09:00", "FREQ=YEARLY;BYDAY=FR;BYMONTHDAY=13"),
+			"1997-09-03", "2000-11-01",
+			[]string{"1998-02-13 09:00", "1998-03-13 09:00", "1998-11-13 09:00", "1999-08-13 09:00", "2000-10-13 09:00"},
+		},
+		{
+			"first and last day of every month by year",
+			newEvent("2026-01-15 09:00", "FREQ=YEARLY;BYMONTHDAY=1,-1;COUNT=5"),
+			"2026-01-01", "2027-01-01",
+			[]string{"2026-01-15 09:00", "2026-01-31 09:00", "2026-02-01 09:00", "2026-02-28 09:00", "2026-03-01 09:00"},
+		},
+		{
+			"february 29th every 4 years, 8 years around 2100",
+			newEvent("2024-02-29 10:00", "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29"),
+			"2024-01-01", "2105-01-01",
+			[]string{"2024-02-29 10:00", "2028-02-29 10:00", "2032-02-29 10:00", "2036-02-29 10:00", "2040-02-29 10:00",
+				"2044-02-29 10:00", "2048-02-29 10:00", "2052-02-29 10:00", "2056-02-29 10:00", "2060-02-29 10:00",
+				"2064-02-29 10:00", "2068-02-29 10:00", "2072-02-29 10:00", "2076-02-29 10:00", "2080-02-29 10:00",
+				"2084-02-29 10:00", "2088-02-29 10:00", "2092-02-29 10:00", "2096-02-29 10:00", "2104-02-29 10:00"},
+		},
+		{
+			"february 29th on a monday",
+			newEvent("2016-02-29 10:00", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29;BYDAY=MO;COUNT=3"),
+			"2016-01-01", "2100-01-01",
+			[]string{"2016-02-29 10:00", "2044-02-29 10:00", "2072-02-29 10:00"},
+		},
+		{
+			"daily on weekdays",
+			newEvent("2026-10-16 08:00", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=3"),
+			"2026-01-01", "2027-01-01",
+			[]string{"2026-10-16 08:00", "2026-10-19 08:00", "2026-10-20 08:00"},
+		},
+	}
+	for _, c := range cases {
+		start, end := window(c.from, c.to)
+		if ret := occurrenceDates(c.evt.Occurrences(start, end, newYork), newYork); !sameStrings(ret, c.expected) {
+			t.Logf("%s should be %v instead of %v", c.name, c.expected, ret)
+			t.FailNow()
+		}
+	}
+
+	// EXDATE and RDATE
+	evt := newEvent("2026-10-19 09:00", "FREQ=DAILY;COUNT=3")
+	evt.ExDates = []time.Time{time.Date(2026, 10, 20, 9, 0, 0, 0, newYork)}
+	evt.RDates = []time.Time{time.Date(2026, 10, 25, 14, 0, 0, 0, newYork)}
+	start, end := window("2026-10-01", "2026-11-01")
+	occurrences := evt.Occurrences(start, end, newYork)
+	expected := []string{"2026-10-19 09:00", "2026-10-21 09:00", "2026-10-25 14:00"}
+	if ret := occurrenceDates(occurrences, newYork); !sameStrings(ret, expected) {
+		t.Logf("occurrences should be %v instead of %v", expected, ret)
+		t.FailNow()
+	}
+	for _, occ := range occurrences {
+		if occ.Recurrence != nil || occ.Id != 0 || occ.RecurrenceId != occ.Start || occ.Duration() != 30*time.Minute {
+			t.Logf("occurrence is wrong: %v", occ)
+			t.FailNow()
+		}
+	}
+}
+
+func TestFindOccurrenceOverlaps(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	standup := NewZonedEvent(0, time.Date(2026, 10, 19, 9, 0, 0, 0, newYork), time.Date(2026, 10, 19, 9, 15, 0, 0, newYork))
+	standup.Recurrence = mustParseRule(t, "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR")
+	review := NewZonedEvent(1, time.Date(2026, 10, 21, 9, 10, 0, 0, newYork), time.Date(2026, 10, 21, 10, 0, 0, 0, newYork))
+	review.Recurrence = mustParseRule(t, "FREQ=WEEKLY;INTERVAL=2")
+	lunch := NewZonedEvent(2, time.Date(2026, 10, 22, 12, 0, 0, 0, newYork), time.Date(2026, 10, 22, 13, 0, 0, 0, newYork))
+
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, newYork).Unix()
+	end := time.Date(2026, 11, 8, 0, 0, 0, 0, newYork).Unix()
+	ret, err := FindOccurrenceOverlaps(Events{standup, review, lunch}, start, end, newYork, Options{})
+	if err != nil || len(ret) != 2 {
+		t.Logf("it should find 2 conflicts instead of %v, %v", ret, err)
+		t.FailNow()
+	}
+	expected := []string{"2026-10-21 09:00", "2026-10-21 09:10", "2026-11-04 09:00", "2026-11-04 09:10"}
+	var found []string
+	for _, pair := range ret {
+		if pair.First.Id != 0 || pair.Second.Id != 1 {
+			t.Logf("conflict should be between standup and review: %v", pair)
+			t.FailNow()
+		}
+		found = append(found, occurrenceDates([]Occurrence{pair.First, pair.Second}, newYork)...)
+	}
+	if !sameStrings(found, expected) {
+		t.Logf("conflicts should be %v instead of %v", expected, found)
+		t.FailNow()
+	}
+}
+
+func TestAllDayRecurrence(t *testing.T) {
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	evt := NewAllDayEvent(0, Date{2026, time.December, 24}, Date{2026, time.December, 25})
+	evt.Recurrence = mustParseRule(t, "FREQ=YEARLY;UNTIL=20281224")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, tokyo).Unix()
+	end := time.Date(2030, 1, 1, 0, 0, 0, 0, tokyo).Unix()
+	occurrences := evt.Occurrences(start, end, tokyo)
+	if len(occurrences) != 3 {
+		t.Logf("it should have 3 occurrences instead of %d", len(occurrences))
+		t.FailNow()
+	}
+	if first, last := occurrences[2].Dates(); first.String() != "2028-12-24" || last.String() != "2028-12-25" {
+		t.Logf("last occurrence should be 2028-12-24 -> 2028-12-25 instead of %s -> %s", first, last)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,145 @@
+package calendar
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errorNotRecurring = errors.New("event is not recurring")
+var errorNoOccurrence = errors.New("no such occurrence")
+
+// check the series has an occurrence originally starting at recurrenceId
+func (c *Event) hasOccurrence(recurrenceId int64) bool {
+	if c.Recurrence == nil && len(c.RDates) == 0 {
+		return false
+	}
+	for _, start := range c.occurrenceStarts(recurrenceId) {
+		if start == recurrenceId {
+			return true
+		}
+	}
+	return false
+}
+
+// replace the occurrence originally starting at recurrenceId with instance, e.g. moving it
+// Id, Recurrence, RDates, ExDates and Overrides of instance are ignored
+func (c *Event) Override(recurrenceId int64, instance Event) error {
+	if !c.hasOccurrence(recurrenceId) {
+		return errors.Wrapf(errorNoOccurrence, "event %d at %d", c.Id, recurrenceId)
+	}
+	instance.Id = c.Id
+	instance.Recurrence = nil
+	instance.RDates = nil
+	instance.ExDates = nil
+	instance.Overrides = nil
+	if c.Overrides == nil {
+		c.Overrides = make(map[int64]Event)
+	}
+	c.Overrides[recurrenceId] = instance
+	return nil
+}
+
+// cancel the occurrence originally starting at recurrenceId, any override of it is dropped
+func (c *Event) Cancel(recurrenceId int64) error {
+	if !c.hasOccurrence(recurrenceId) {
+		return errors.Wrapf(errorNoOccurrence, "event %d at %d", c.Id, recurrenceId)
+	}
+	delete(c.Overrides, recurrenceId)
+	c.ExDates = append(c.ExDates, time.Unix(recurrenceId, 0).UTC())
+	return nil
+}
+
+// take the occurrence out of the series as a standalone event with newId
+// the override of it is kept in the new event if there is one
+func (c *Event) Detach(recurrenceId int64, newId int) (ret Event, err error) {
+	if !c.hasOccurrence(recurrenceId) {
+		err = errors.Wrapf(errorNoOccurrence, "event %d at %d", c.Id, recurrenceId)
+		return
+	}
+	if override, ok := c.Overrides[recurrenceId]; ok {
+		ret = override
+	} else {
+		ret = *c
+		ret.CalendarEvent = CalendarEvent{c.Id, recurrenceId, recurrenceId + c.End - c.Start}
+		ret.Recurrence = nil
+		ret.RDates = nil
+		ret.ExDates = nil
+		ret.Overrides = nil
+	}
+	ret.Id = newId
+	ret.Metadata = copyMetadata(ret.Metadata)
+	ret.Attendees = append([]Attendee(nil), ret.Attendees...)
+	err = c.Cancel(recurrenceId)
+	return
+}
+
+// split the series at the occurrence originally starting at recurrenceId, for "this and following"
+// the event keeps its id and every occurrence before, the returned series with newId starts
+// at recurrenceId, so occurrences are still identified by series id and recurrence id
+func (c *Event) Split(recurrenceId int64, newId int) (tail Event, err error) {
+	if c.Recurrence == nil {
+		err = errors.Wrapf(errorNotRecurring, "event %d", c.Id)
+		return
+	}
+	// the new series starts the rule again from recurrenceId, so it must be generated by the rule
+	starts := c.ruleStarts(recurrenceId)
+	if recurrenceId <= c.Start || starts[len(starts)-1] != recurrenceId {
+		err = errors.Wrapf(errorNoOccurrence, "event %d at %d", c.Id, recurrenceId)
+		return
+	}
+
+	tail = *c
+	tail.Id = newId
+	tail.CalendarEvent = CalendarEvent{newId, recurrenceId, recurrenceId + c.End - c.Start}
+	tail.Metadata = copyMetadata(c.Metadata)
+	tail.Attendees = append([]Attendee(nil), c.Attendees...)
+
+	head := *c.Recurrence
+	rest := *c.Recurrence
+	if c.Recurrence.Count > 0 {
+		// COUNT includes the excluded occurrences, so count the rule only
+		head.Count = len(starts) - 1
+		rest.Count = c.Recurrence.Count - head.Count
+	} else {
+		head.Until = time.Unix(recurrenceId-1, 0).UTC()
+	}
+	c.Recurrence = &head
+	tail.Recurrence = &rest
+
+	c.RDates, tail.RDates = splitDates(c.RDates, recurrenceId)
+	c.ExDates, tail.ExDates = splitDates(c.ExDates, recurrenceId)
+	overrides := c.Overrides
+	c.Overrides, tail.Overrides = make(map[int64]Event), make(map[int64]Event)
+	for start, override := range overrides {
+		if start < recurrenceId {
+			c.Overrides[start] = override
+		} else {
+			override.Id = newId
+			tail.Overrides[start] = override
+		}
+	}
+	return
+}
+
+func splitDates(dates []time.Time, at int64) (before, after []time.Time) {
+	for _, date := range dates {
+		if date.Unix() < at {
+			before = append(before, date)
+		} else {
+			after = append(after, date)
+		}
+	}
+	return
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	ret := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		ret[k] = v
+	}
+	return ret
+}
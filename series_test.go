@@ -0,0 +1,133 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func newWeeklySeries(t *testing.T, loc *time.Location, rule string) Event {
+	evt := NewZonedEvent(7, time.Date(2026, 10, 19, 9, 0, 0, 0, loc), time.Date(2026, 10, 19, 10, 0, 0, 0, loc))
+	evt.Title = "Sync"
+	evt.Metadata = map[string]string{"team": "core"}
+	evt.Recurrence = mustParseRule(t, rule)
+	return evt
+}
+
+func TestEvent_OverrideCancel(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	evt := newWeeklySeries(t, newYork, "FREQ=WEEKLY;COUNT=4")
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, newYork).Unix()
+	end := time.Date(2026, 12, 1, 0, 0, 0, 0, newYork).Unix()
+
+	second := time.Date(2026, 10, 26, 9, 0, 0, 0, newYork).Unix()
+	moved := Event{CalendarEvent: NewCalendarEvent(0, time.Date(2026, 10, 27, 14, 0, 0, 0, newYork), time.Date(2026, 10, 27, 15, 0, 0, 0, newYork)), Title: "Sync (moved)"}
+	if err := evt.Override(second, moved); err != nil {
+		t.Logf("override failed: %s", err)
+		t.FailNow()
+	}
+	if err := evt.Override(second+60, moved); err == nil {
+		t.Log("override of a missing occurrence should fail")
+		t.FailNow()
+	}
+	if err := evt.Cancel(time.Date(2026, 11, 2, 9, 0, 0, 0, newYork).Unix()); err != nil {
+		t.Logf("cancel failed: %s", err)
+		t.FailNow()
+	}
+
+	occurrences := evt.Occurrences(start, end, newYork)
+	expected := []string{"2026-10-19 09:00", "2026-10-27 14:00", "2026-11-09 09:00"}
+	if ret := occurrenceDates(occurrences, newYork); !sameStrings(ret, expected) {
+		t.Logf("occurrences should be %v instead of %v", expected, ret)
+		t.FailNow()
+	}
+	if occurrences[1].Id != 7 || occurrences[1].RecurrenceId != second || occurrences[1].Title != "Sync (moved)" {
+		t.Logf("overridden occurrence is wrong: %v", occurrences[1])
+		t.FailNow()
+	}
+
+	// the overridden time is what conflict detection sees
+	other := NewZonedEvent(8, time.Date(2026, 10, 27, 14, 30, 0, 0, newYork), time.Date(2026, 10, 27, 14, 45, 0, 0, newYork))
+	ret, err := FindOccurrenceOverlaps(Events{evt, other}, start, end, newYork, Options{})
+	if err != nil || len(ret) != 1 || ret[0].First.RecurrenceId != second {
+		t.Logf("moved occurrence should conflict instead of %v, %v", ret, err)
+		t.FailNow()
+	}
+}
+
+func TestEvent_Detach(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	evt := newWeeklySeries(t, newYork, "FREQ=WEEKLY;COUNT=3")
+	third := time.Date(2026, 11, 2, 9, 0, 0, 0, newYork).Unix()
+	detached, err := evt.Detach(third, 100)
+	if err != nil {
+		t.Logf("detach failed: %s", err)
+		t.FailNow()
+	}
+	if detached.Id != 100 || detached.Start != third || detached.Recurrence != nil || detached.Title != "Sync" {
+		t.Logf("detached event is wrong: %v", detached)
+		t.FailNow()
+	}
+	detached.Metadata["team"] = "other"
+	if evt.Metadata["team"] != "core" {
+		t.Log("detached event should not share metadata")
+		t.FailNow()
+	}
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, newYork).Unix()
+	end := time.Date(2026, 12, 1, 0, 0, 0, 0, newYork).Unix()
+	if occurrences := evt.Occurrences(start, end, newYork); len(occurrences) != 2 {
+		t.Logf("series should have 2 occurrences left instead of %d", len(occurrences))
+		t.FailNow()
+	}
+	if _, err := evt.Detach(third, 101); err == nil {
+		t.Log("detach twice should fail")
+		t.FailNow()
+	}
+}
+
+func TestEvent_Split(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, newYork).Unix()
+	end := time.Date(2027, 1, 1, 0, 0, 0, 0, newYork).Unix()
+	third := time.Date(2026, 11, 2, 9, 0, 0, 0, newYork).Unix()
+	fifth := time.Date(2026, 11, 16, 9, 0, 0, 0, newYork).Unix()
+
+	for _, rule := range []string{"FREQ=WEEKLY;COUNT=6", "FREQ=WEEKLY;UNTIL=20261124T000000Z"} {
+		evt := newWeeklySeries(t, newYork, rule)
+		// cancel the second and move the fifth
+		evt.Cancel(time.Date(2026, 10, 26, 9, 0, 0, 0, newYork).Unix())
+		evt.Override(fifth, Event{CalendarEvent: CalendarEvent{0, fifth + 3600, fifth + 7200}})
+		before := occurrenceDates(evt.Occurrences(start, end, newYork), newYork)
+
+		tail, err := evt.Split(third, 8)
+		if err != nil {
+			t.Logf("%s split failed: %s", rule, err)
+			t.FailNow()
+		}
+		head := evt.Occurrences(start, end, newYork)
+		rest := tail.Occurrences(start, end, newYork)
+		if len(head) != 1 || head[0].Id != 7 {
+			t.Logf("%s head should only have the first occurrence instead of %v", rule, occurrenceDates(head, newYork))
+			t.FailNow()
+		}
+		for _, occ := range rest {
+			if occ.Id != 8 {
+				t.Logf("%s tail occurrence should have id 8: %v", rule, occ)
+				t.FailNow()
+			}
+		}
+		if after := append(occurrenceDates(head, newYork), occurrenceDates(rest, newYork)...); !sameStrings(before, after) {
+			t.Logf("%s occurrences should not change by split: %v, %v", rule, before, after)
+			t.FailNow()
+		}
+	}
+
+	evt := newWeeklySeries(t, newYork, "FREQ=WEEKLY")
+	if _, err := evt.Split(evt.Start, 8); err == nil {
+		t.Log("split at first occurrence should fail")
+		t.FailNow()
+	}
+	if _, err := evt.Split(third+60, 8); err == nil {
+		t.Log("split at missing occurrence should fail")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,235 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// daily hours meetings can be put in, e.g. 9:00 to 17:00 from Monday to Friday
+type WorkingHours struct {
+	// since midnight, the meeting has to end by End
+	Start time.Duration
+	End   time.Duration
+	// zone of the hours, UTC when nil
+	Zone *time.Location
+	// days with working hours, every day when empty
+	Weekdays []time.Weekday
+}
+
+type SlotPreference int
+
+const (
+	PreferEarlier SlotPreference = iota // default
+	PreferLater
+)
+
+type SlotOptions struct {
+	// nil means any time of any day
+	WorkingHours *WorkingHours
+	// least number of attendees free, 0 means everyone
+	Quorum int
+	// attendees should be free this long before and after the meeting too
+	BufferBefore time.Duration
+	BufferAfter  time.Duration
+	// candidate starts are multiples of Step, 15 minutes by default
+	Step time.Duration
+	// among slots with as many free attendees the earlier or later ones come first
+	Prefer SlotPreference
+	// max number of slots returned, 0 means every candidate
+	Limit int
+	// boundary of the events of the calendars
+	Boundary BoundaryMode
+}
+
+// a candidate meeting time, Start and End are closed
+type Slot struct {
+	Interval
+	// index of the calendars free and busy during the slot, in order
+	Free []int
+	Busy []int
+}
+
+var errorInvalidSlotRequest = errors.New("invalid slot request")
+
+// FindSlots returns the meeting times within [windowStart, windowEnd] when at least the quorum
+// of the calendars is free, ranked by number of free calendars then by the preference
+// busy time of all calendars is kept as one Segments timeline with the ids of the busy
+// calendars, so checking a candidate only walks the segments it covers
+func FindSlots(calendars []CalendarEvents, duration time.Duration, windowStart, windowEnd int64, opts SlotOptions) ([]Slot, error) {
+	if len(calendars) == 0 {
+		return nil, errors.Wrap(errorInvalidSlotRequest, "no calendars")
+	}
+	length := int64(duration / time.Second)
+	if length <= 0 {
+		return nil, errors.Wrapf(errorInvalidSlotRequest, "duration %s should be at least one second", duration)
+	}
+	quorum := opts.Quorum
+	if quorum == 0 {
+		quorum = len(calendars)
+	}
+	if quorum < 0 || quorum > len(calendars) {
+		return nil, errors.Wrapf(errorInvalidSlotRequest, "quorum %d of %d calendars", opts.Quorum, len(calendars))
+	}
+	step := int64(opts.Step / time.Second)
+	if step <= 0 {
+		step = 15 * 60
+	}
+	if windowEnd < windowStart {
+		return nil, errors.Wrapf(errorInvalidSlotRequest, "window ends at %d before it starts at %d", windowEnd, windowStart)
+	}
+
+	timeline := busyTimeline(calendars, windowStart, windowEnd, opts)
+	var ret []Slot
+	for _, period := range workingPeriods(windowStart, windowEnd, opts.WorkingHours) {
+		// first multiple of step at or after the period start
+		start := period.Start + (step-period.Start%step)%step
+		for ; start+length-1 <= period.End; start += step {
+			busy := timeline.busyWithin(start, start+length-1)
+			if len(calendars)-len(busy) < quorum {
+				continue
+			}
+			ret = append(ret, Slot{Interval{start, start + length - 1}, freeOf(len(calendars), busy), busy})
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if len(ret[i].Free) != len(ret[j].Free) {
+			return len(ret[i].Free) > len(ret[j].Free)
+		}
+		if opts.Prefer == PreferLater {
+			return ret[i].Start > ret[j].Start
+		}
+		return ret[i].Start < ret[j].Start
+	})
+	if opts.Limit > 0 && len(ret) > opts.Limit {
+		ret = ret[:opts.Limit]
+	}
+	return ret, nil
+}
+
+// merged busy blocks of every calendar grown by the buffers, as segments listing the busy
+// calendars, time nobody is busy has no segment
+// a meeting [s, e] hits a block [a, b] with buffers when [a - after, b + before] overlaps [s, e]
+func busyTimeline(calendars []CalendarEvents, windowStart, windowEnd int64, opts SlotOptions) Segments {
+	before := int64(opts.BufferBefore / time.Second)
+	after := int64(opts.BufferAfter / time.Second)
+	type point struct {
+		value int64
+		id    int
+		delta int
+	}
+	var points []point
+	for id, evts := range calendars {
+		// blocks just outside the window still matter because of the buffers
+		busy, _ := FreeBusyMode(evts, windowStart-before, windowEnd+after, opts.Boundary)
+		grown := make([]Interval, len(busy))
+		for i, block := range busy {
+			grown[i] = Interval{block.Start - after, block.End + before}
+		}
+		for _, block := range mergeIntervals(grown) {
+			points = append(points, point{block.Start, id, 1}, point{block.End + 1, id, -1})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].value < points[j].value
+	})
+
+	var segs Segments
+	active := make(map[int]bool)
+	for i := 0; i < len(points); {
+		value := points[i].value
+		for ; i < len(points) && points[i].value == value; i++ {
+			if points[i].delta > 0 {
+				active[points[i].id] = true
+			} else {
+				delete(active, points[i].id)
+			}
+		}
+		if len(active) == 0 || i == len(points) {
+			continue
+		}
+		ids := make([]int, 0, len(active))
+		for id := range active {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		segs = append(segs, Segment{value, points[i].value - 1, ids})
+	}
+	return segs
+}
+
+// ids of the segments overlapping [start, end], in order
+func (c Segments) busyWithin(start, end int64) []int {
+	idx, err := c.FindSeg(start)
+	if err == errorTooSmall {
+		idx = 0
+	} else if err == errorTooLarge {
+		return nil
+	} else if err == errorInBetween && c[idx].End < start {
+		idx++
+	}
+	seen := make(map[int]bool)
+	var ret []int
+	for ; idx < len(c) && c[idx].Start <= end; idx++ {
+		for _, id := range c[idx].Ids {
+			if !seen[id] {
+				seen[id] = true
+				ret = append(ret, id)
+			}
+		}
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+func freeOf(n int, busy []int) []int {
+	ret := make([]int, 0, n-len(busy))
+	j := 0
+	for i := 0; i < n; i++ {
+		if j < len(busy) && busy[j] == i {
+			j++
+			continue
+		}
+		ret = append(ret, i)
+	}
+	return ret
+}
+
+// the working hours within the window, the whole window without hours
+func workingPeriods(windowStart, windowEnd int64, hours *WorkingHours) (ret []Interval) {
+	if hours == nil {
+		return []Interval{{windowStart, windowEnd}}
+	}
+	loc := hours.Zone
+	if loc == nil {
+		loc = time.UTC
+	}
+	days := make(map[time.Weekday]bool)
+	for _, day := range hours.Weekdays {
+		days[day] = true
+	}
+	// start a day early as the window may start in the middle of the working hours
+	first := time.Unix(windowStart, 0).In(loc)
+	date := DateOf(first).AddDays(-1)
+	for {
+		midnight := date.wallClock()
+		start := WallClockIn(midnight.Add(hours.Start), loc).Unix()
+		end := WallClockIn(midnight.Add(hours.End), loc).Unix() - 1
+		if start > windowEnd {
+			return
+		}
+		if len(days) == 0 || days[midnight.Weekday()] {
+			if start < windowStart {
+				start = windowStart
+			}
+			if end > windowEnd {
+				end = windowEnd
+			}
+			if start <= end {
+				ret = append(ret, Interval{start, end})
+			}
+		}
+		date = date.AddDays(1)
+	}
+}
@@ -0,0 +1,158 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestFindSlots(t *testing.T) {
+	// monday
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC).Unix()
+	at := func(hour, minute int) int64 {
+		return day + int64(hour*3600+minute*60)
+	}
+	calendars := []CalendarEvents{
+		{{0, at(9, 0), at(10, 0) - 1}, {1, at(12, 0), at(13, 0) - 1}},
+		{{0, at(10, 30), at(11, 0) - 1}},
+		{{0, at(9, 0), at(11, 30) - 1}},
+	}
+	hours := &WorkingHours{Start: 9 * time.Hour, End: 17 * time.Hour, Weekdays: []time.Weekday{time.Monday, time.Tuesday}}
+
+	slots, err := FindSlots(calendars, time.Hour, day, at(23, 59), SlotOptions{WorkingHours: hours, Step: 30 * time.Minute, Limit: 3})
+	if err != nil || len(slots) != 3 {
+		t.Logf("expect 3 slots but got %v %v", slots, err)
+		t.FailNow()
+	}
+	if slots[0].Start != at(13, 0) || slots[0].End != at(14, 0)-1 || slots[2].Start != at(14, 0) || len(slots[0].Free) != 3 {
+		t.Logf("wrong slots %v", slots)
+		t.FailNow()
+	}
+	slots, _ = FindSlots(calendars, time.Hour, day, at(23, 59), SlotOptions{WorkingHours: hours, Step: 30 * time.Minute, Prefer: PreferLater, Limit: 1})
+	if slots[0].Start != at(16, 0) {
+		t.Logf("expect the last slot of the day but got %s", slots[0].ToString())
+		t.FailNow()
+	}
+
+	// 11:30 to 12:00 is free for everyone but too short with buffers
+	slots, _ = FindSlots(calendars, 30*time.Minute, at(11, 0), at(12, 30), SlotOptions{Step: 30 * time.Minute})
+	if len(slots) != 1 || slots[0].Start != at(11, 30) {
+		t.Logf("expect 11:30 but got %v", slots)
+		t.FailNow()
+	}
+	slots, _ = FindSlots(calendars, 30*time.Minute, at(11, 0), at(12, 30), SlotOptions{Step: 30 * time.Minute, BufferAfter: time.Minute})
+	if len(slots) != 0 {
+		t.Logf("buffer should rule out 11:30 but got %v", slots)
+		t.FailNow()
+	}
+
+	// with a quorum of 2 the morning opens up, slots with everyone still come first
+	slots, _ = FindSlots(calendars, time.Hour, day, at(23, 59), SlotOptions{WorkingHours: hours, Quorum: 2, Step: 30 * time.Minute})
+	if slots[0].Start != at(13, 0) || len(slots[0].Busy) != 0 {
+		t.Logf("wrong first slot %v", slots[0])
+		t.FailNow()
+	}
+	var morning *Slot
+	for i := range slots {
+		if slots[i].Start == at(9, 30) {
+			morning = &slots[i]
+		}
+	}
+	// 9:30 to 10:30 hits the first and third calendars
+	if morning != nil {
+		t.Logf("9:30 has only one calendar free %v", morning)
+		t.FailNow()
+	}
+	for i := range slots {
+		if slots[i].Start == at(10, 0) {
+			morning = &slots[i]
+		}
+	}
+	if morning != nil {
+		t.Logf("10:00 has only the first calendar free %v", morning)
+		t.FailNow()
+	}
+	for i := range slots {
+		if slots[i].Start == at(11, 0) {
+			morning = &slots[i]
+		}
+	}
+	if morning == nil || !reflect.DeepEqual(morning.Free, []int{0, 1}) || !reflect.DeepEqual(morning.Busy, []int{2}) {
+		t.Logf("11:00 should have the first two calendars free %v", morning)
+		t.FailNow()
+	}
+
+	for _, opts := range []SlotOptions{{Quorum: 4}, {Quorum: -1}} {
+		if _, err := FindSlots(calendars, time.Hour, day, at(23, 59), opts); errors.Cause(err) != errorInvalidSlotRequest {
+			t.Logf("expect invalid request but got %v", err)
+			t.FailNow()
+		}
+	}
+	if _, err := FindSlots(nil, time.Hour, day, at(23, 59), SlotOptions{}); errors.Cause(err) != errorInvalidSlotRequest {
+		t.Logf("expect invalid request but got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestWorkingPeriods(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	hours := &WorkingHours{Start: 9 * time.Hour, End: 17 * time.Hour, Zone: newYork, Weekdays: []time.Weekday{time.Friday, time.Monday}}
+	// friday before to monday after the end of DST
+	start := time.Date(2026, 10, 30, 12, 0, 0, 0, newYork).Unix()
+	end := time.Date(2026, 11, 2, 23, 0, 0, 0, newYork).Unix()
+	periods := workingPeriods(start, end, hours)
+	expect := []Interval{
+		{start, time.Date(2026, 10, 30, 17, 0, 0, 0, newYork).Unix() - 1},
+		{time.Date(2026, 11, 2, 9, 0, 0, 0, newYork).Unix(), time.Date(2026, 11, 2, 17, 0, 0, 0, newYork).Unix() - 1},
+	}
+	if !reflect.DeepEqual(periods, expect) {
+		t.Logf("expect %v but got %v", expect, periods)
+		t.FailNow()
+	}
+	if periods[1].Start-periods[0].End != 2*24*3600+16*3600+3600+1 {
+		t.Logf("monday should be an hour later in UTC")
+		t.FailNow()
+	}
+}
+
+func TestFindSlotsMany(t *testing.T) {
+	// hundreds of calendars, each busy for an hour somewhere in the week
+	week := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC).Unix()
+	var calendars []CalendarEvents
+	for i := 0; i < 500; i++ {
+		start := week + int64(i%40)*3600*4
+		calendars = append(calendars, CalendarEvents{{0, start, start + 3599}, {1, start + 3600*24, start + 3600*25 - 1}})
+	}
+	hours := &WorkingHours{Start: 9 * time.Hour, End: 17 * time.Hour}
+	slots, err := FindSlots(calendars, 30*time.Minute, week, week+7*24*3600-1, SlotOptions{WorkingHours: hours, Quorum: 490})
+	if err != nil || len(slots) == 0 {
+		t.Logf("expect slots but got %v", err)
+		t.FailNow()
+	}
+	for _, slot := range slots {
+		if len(slot.Free) < 490 || len(slot.Free)+len(slot.Busy) != 500 {
+			t.Logf("wrong slot %v", slot)
+			t.FailNow()
+		}
+		for _, id := range slot.Busy {
+			hit := false
+			for _, evt := range calendars[id] {
+				if evt.Start <= slot.End && slot.Start <= evt.End {
+					hit = true
+				}
+			}
+			if !hit {
+				t.Logf("calendar %d is not busy during %s", id, slot.ToString())
+				t.FailNow()
+			}
+		}
+	}
+	for i := 1; i < len(slots); i++ {
+		if len(slots[i].Free) > len(slots[i-1].Free) || (len(slots[i].Free) == len(slots[i-1].Free) && slots[i].Start < slots[i-1].Start) {
+			t.Logf("slots are not ranked at %d", i)
+			t.FailNow()
+		}
+	}
+}
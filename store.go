@@ -0,0 +1,532 @@
+package calendar
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Store keeps events by id, implementations are safe for concurrent use
+// events going in and out are copies, changing them does not change the store
+// every stored event has a Version, update and delete only happen when the version given
+// matches, otherwise they fail with *VersionConflictError, version 0 matches any
+// conflicts between long series are only looked for up to StoreOptions.Horizon unless they
+// can be worked out from the rules, see StoreOptions.Horizon
+type Store interface {
+	// store evt under a new id at version 1, the id and version of evt are ignored
+	// returns the stored event and the ids of events it overlaps with
+	Create(evt Event) (Event, []int, error)
+	Get(id int) (Event, error)
+	// replace the event with the id of evt if evt.Version matches
+	// returns the stored event with the next version and the ids of events it overlaps with
+	Update(evt Event) (Event, []int, error)
+	Delete(id int, version int64) error
+	// events with an occurrence overlapping [start, end], ordered by their first start then Id
+	List(start, end int64) (Events, error)
+	// apply every op in order or none of them, the error tells the index of the failed op
+	Batch(ops []BatchOp) ([]BatchResult, error)
+}
+
+type ConflictPolicy int
+
+const (
+	ConflictReport ConflictPolicy = iota // default, overlapping writes are stored and the conflicts returned
+	ConflictReject                       // overlapping writes fail with ErrOverlap, as far as they are checked
+)
+
+type StoreOptions struct {
+	Conflicts ConflictPolicy
+	// zone floating and all-day events are resolved in, UTC when nil
+	Zone *time.Location
+	// how the bounds of events are treated when checking conflicts
+	Boundary BoundaryMode
+	// all-day events are ignored in conflicts by default
+	AllDay AllDayPolicy
+	// how far conflicts are looked for when two events share more than this, a year by default
+	// two endless DAILY or WEEKLY series in the same zone are checked over the period their
+	// occurrences repeat after instead, so they are exact however far apart their collisions are
+	// any other check cut short is told to Logf, a collision after it is not reported
+	Horizon time.Duration
+	// called with what the store could not check, nothing is told when nil
+	Logf func(format string, args ...interface{})
+}
+
+func (c *StoreOptions) zone() *time.Location {
+	if c.Zone == nil {
+		return time.UTC
+	}
+	return c.Zone
+}
+
+func (c *StoreOptions) horizon() int64 {
+	if c.Horizon <= 0 {
+		return 366 * 24 * 3600
+	}
+	return int64(c.Horizon / time.Second)
+}
+
+var ErrNotFound = errors.New("event not found")
+var ErrOverlap = errors.New("event overlaps existing events")
+var errorInvalidEvent = errors.New("invalid event")
+
+// end of the span of series without end
+const endlessSpan = math.MaxInt64 / 2
+
+// longest period two endless series are checked over, beyond it Horizon applies
+const maxPeriodCheck = 10 * 366 * 24 * 3600
+
+// longest a COUNT or UNTIL series is expanded over for its span, one going on longer is
+// spanned as an endless series, so its conflicts are checked as those of endless ones
+const maxSpanExpand = 10 * 366 * 24 * 3600
+
+// MemoryStore is a Store in memory, reads share a lock so they run in parallel
+type MemoryStore struct {
+	mu     sync.RWMutex
+	opts   StoreOptions
+	events map[int]*Event
+	// span of every event from the start of its first occurrence to the end of its last one
+	spans  *IntervalTree
+	nextId int
+}
+
+func NewMemoryStore(opts StoreOptions) *MemoryStore {
+	return &MemoryStore{
+		opts:   opts,
+		events: make(map[int]*Event),
+		spans:  NewIntervalTree(nil),
+	}
+}
+
+// spans of the events written are worked out before taking the lock, so expanding a
+// series does not stall the other readers and writers
+func (c *MemoryStore) Create(evt Event) (Event, []int, error) {
+	span := eventSpan(&evt, c.opts.zone())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.create(evt, span)
+}
+
+func (c *MemoryStore) Get(id int) (Event, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	evt, ok := c.events[id]
+	if !ok {
+		return Event{}, errors.Wrapf(ErrNotFound, "id %d", id)
+	}
+	return cloneEvent(*evt), nil
+}
+
+func (c *MemoryStore) Update(evt Event) (Event, []int, error) {
+	span := eventSpan(&evt, c.opts.zone())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.update(evt, span)
+}
+
+func (c *MemoryStore) Delete(id int, version int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.delete(id, version)
+}
+
+func (c *MemoryStore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	spans := make([]CalendarEvent, len(ops))
+	for i := range ops {
+		if ops[i].Kind == BatchCreate || ops[i].Kind == BatchUpdate {
+			spans[i] = eventSpan(&ops[i].Event, c.opts.zone())
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batch(ops, spans)
+}
+
+func (c *MemoryStore) List(start, end int64) (Events, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var ret Events
+	loc := c.opts.zone()
+	for _, span := range c.spans.Overlapping(start, end) {
+		evt := c.events[span.Id]
+		// the span of a series has gaps between occurrences, the first one found is enough
+		// as expanding an endless series over a wide window would never end
+		if isSeries(evt) && !evt.occursWithin(start, end, loc) {
+			continue
+		}
+		ret = append(ret, cloneEvent(*evt))
+	}
+	return ret, nil
+}
+
+// number of events stored
+func (c *MemoryStore) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.events)
+}
+
+// the lock is held by the caller for create, update, delete and batch, span is eventSpan of evt
+func (c *MemoryStore) create(evt Event, span CalendarEvent) (Event, []int, error) {
+	evt = cloneEvent(evt)
+	evt.Id = c.nextId
+	evt.Version = 1
+	conflicts, err := c.put(&evt, span)
+	if err != nil {
+		return Event{}, conflicts, err
+	}
+	c.nextId++
+	return cloneEvent(evt), conflicts, nil
+}
+
+func (c *MemoryStore) update(evt Event, span CalendarEvent) (Event, []int, error) {
+	old, err := c.match(evt.Id, evt.Version)
+	if err != nil {
+		return Event{}, nil, err
+	}
+	evt = cloneEvent(evt)
+	evt.Version = old.Version + 1
+	conflicts, err := c.put(&evt, span)
+	if err != nil {
+		return Event{}, conflicts, err
+	}
+	return cloneEvent(evt), conflicts, nil
+}
+
+func (c *MemoryStore) delete(id int, version int64) error {
+	if _, err := c.match(id, version); err != nil {
+		return err
+	}
+	c.unset(id)
+	return nil
+}
+
+// ops are applied one by one, so later ops see the earlier ones, spans has the span of every event
+// on failure the events replaced so far are put back
+func (c *MemoryStore) batch(ops []BatchOp, spans []CalendarEvent) ([]BatchResult, error) {
+	nextId := c.nextId
+	type undo struct {
+		id       int
+		previous *Event
+		span     CalendarEvent
+	}
+	var undos []undo
+	ret := make([]BatchResult, 0, len(ops))
+	for i, op := range ops {
+		var result BatchResult
+		var err error
+		id := op.Event.Id
+		previous := c.events[id]
+		previousSpan, _ := c.spans.Get(id)
+		switch op.Kind {
+		case BatchCreate:
+			result.Event, result.Conflicts, err = c.create(op.Event, spans[i])
+			id, previous = result.Event.Id, nil
+		case BatchUpdate:
+			result.Event, result.Conflicts, err = c.update(op.Event, spans[i])
+		case BatchDelete:
+			err = c.delete(id, op.Event.Version)
+		default:
+			err = errors.Wrapf(errorInvalidBatch, "unknown kind %s", op.Kind)
+		}
+		if err != nil {
+			for j := len(undos) - 1; j >= 0; j-- {
+				if undos[j].previous == nil {
+					c.unset(undos[j].id)
+				} else {
+					c.set(*undos[j].previous, undos[j].span)
+				}
+			}
+			c.nextId = nextId
+			return nil, errors.Wrapf(err, "op %d %s", i, op.Kind)
+		}
+		undos = append(undos, undo{id, previous, previousSpan})
+		ret = append(ret, result)
+	}
+	return ret, nil
+}
+
+// the stored event if version is 0 or its version
+func (c *MemoryStore) match(id int, version int64) (*Event, error) {
+	evt, ok := c.events[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "id %d", id)
+	}
+	if version != 0 && version != evt.Version {
+		return nil, &VersionConflictError{id, version, evt.Version}
+	}
+	return evt, nil
+}
+
+// check evt and store it unless it is rejected
+func (c *MemoryStore) put(evt *Event, span CalendarEvent) ([]int, error) {
+	if !evt.IsValid() {
+		return nil, errors.Wrapf(errorInvalidEvent, "%s", evt.ToString())
+	}
+	span.Id = evt.Id
+	conflicts, err := c.conflicts(evt, span)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 && c.opts.Conflicts == ConflictReject {
+		return conflicts, errors.Wrapf(ErrOverlap, "event %d overlaps %v", evt.Id, conflicts)
+	}
+	c.events[evt.Id] = evt
+	c.spans.Insert(span)
+	return conflicts, nil
+}
+
+// ids of stored events having an occurrence overlapping one of evt, in order
+// candidates come from the spans, then the occurrences of both are compared by
+// FindOccurrenceOverlaps within the time their spans share
+func (c *MemoryStore) conflicts(evt *Event, span CalendarEvent) (ret []int, err error) {
+	loc := c.opts.zone()
+	opts := Options{Boundary: c.opts.Boundary, AllDay: c.opts.AllDay}
+	for _, other := range c.spans.Overlapping(span.Start, span.End) {
+		if other.Id == evt.Id {
+			continue
+		}
+		windowStart, windowEnd := span.Start, span.End
+		if other.Start > windowStart {
+			windowStart = other.Start
+		}
+		if other.End < windowEnd {
+			windowEnd = other.End
+		}
+		windowEnd = c.checkEnd(evt, c.events[other.Id], windowStart, windowEnd)
+		pairs, err := FindOccurrenceOverlaps(Events{*evt, *c.events[other.Id]}, windowStart, windowEnd, loc, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range pairs {
+			// occurrences of the same series may overlap each other
+			if pair.First.Id != pair.Second.Id {
+				ret = append(ret, other.Id)
+				break
+			}
+		}
+	}
+	sort.Ints(ret)
+	return
+}
+
+// where to stop looking for conflicts of a and b sharing [windowStart, windowEnd]
+// two endless series repeating by days are checked until the pattern of both repeats
+func (c *MemoryStore) checkEnd(a, b *Event, windowStart, windowEnd int64) int64 {
+	if windowEnd-windowStart <= c.opts.horizon() {
+		return windowEnd
+	}
+	if period := c.sharedPeriod(a, b); period > 0 {
+		// after the overrides, RDATEs and EXDATEs of both only the rules are left
+		settled := windowStart
+		for _, evt := range []*Event{a, b} {
+			if last := lastException(evt); last > settled {
+				settled = last
+			}
+		}
+		duration := a.End - a.Start
+		if b.End-b.Start > duration {
+			duration = b.End - b.Start
+		}
+		// a day more for wall clock events
+		end := settled + period + duration + 24*3600
+		if end-windowStart <= maxPeriodCheck {
+			if end < windowEnd {
+				return end
+			}
+			return windowEnd
+		}
+	}
+	end := windowStart + c.opts.horizon()
+	if c.opts.Logf != nil {
+		c.opts.Logf("conflicts of event %d and %d are checked until %s only", a.Id, b.Id, time.Unix(end, 0).UTC().Format(time.RFC3339))
+	}
+	return end
+}
+
+// seconds after which the occurrences of both endless series repeat together by wall clock
+// 0 when they do not repeat within weeks or are in different zones, so DST may move them apart
+func (c *MemoryStore) sharedPeriod(a, b *Event) int64 {
+	if !isEndless(a) || !isEndless(b) || c.wallZone(a).String() != c.wallZone(b).String() {
+		return 0
+	}
+	days, other := a.Recurrence.periodDays(), b.Recurrence.periodDays()
+	if days == 0 || other == 0 {
+		return 0
+	}
+	return days / gcd(days, other) * other * 24 * 3600
+}
+
+// zone the wall clock of evt is in for a user of the store
+func (c *MemoryStore) wallZone(evt *Event) *time.Location {
+	if evt.Floating || evt.AllDay {
+		return c.opts.zone()
+	}
+	return evt.zone()
+}
+
+// latest instant an override, RDATE or EXDATE of evt touches, its start when there are none
+func lastException(evt *Event) int64 {
+	ret := evt.Start
+	for _, dates := range [][]time.Time{evt.RDates, evt.ExDates} {
+		for _, date := range dates {
+			if date.Unix() > ret {
+				ret = date.Unix()
+			}
+		}
+	}
+	for recurrenceId, override := range evt.Overrides {
+		if recurrenceId > ret {
+			ret = recurrenceId
+		}
+		if override.End > ret {
+			ret = override.End
+		}
+	}
+	return ret
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func isEndless(evt *Event) bool {
+	return evt.Recurrence != nil && evt.Recurrence.Count == 0 && evt.Recurrence.Until.IsZero()
+}
+
+func isSeries(evt *Event) bool {
+	return evt.Recurrence != nil || len(evt.RDates) > 0 || len(evt.Overrides) > 0
+}
+
+// from the start of the first occurrence to at least the end of the last one for a user in loc
+// only the starts of the rule are walked, up to maxSpanExpand after the first one
+func eventSpan(evt *Event, loc *time.Location) CalendarEvent {
+	if !isSeries(evt) {
+		return evt.Instants(loc)
+	}
+	ret := evt.Instants(loc)
+	// the rule starts at DTSTART, only RDATEs and moved instances may start before it
+	starts := make([]int64, 0, len(evt.RDates)+len(evt.Overrides))
+	for _, date := range evt.RDates {
+		starts = append(starts, date.Unix())
+	}
+	for recurrenceId := range evt.Overrides {
+		starts = append(starts, recurrenceId)
+	}
+	for _, start := range starts {
+		occ := evt.occurrence(start)
+		if instants := occ.Instants(loc); instants.Start < ret.Start {
+			ret.Start = instants.Start
+		}
+	}
+	endless := isEndless(evt)
+	last := evt.Start
+	if !endless {
+		evt.eachRuleStart(endlessSpan, func(start int64) bool {
+			if start-evt.Start > maxSpanExpand {
+				endless = true
+				return false
+			}
+			last = start
+			return true
+		})
+	}
+	if endless {
+		ret.End = endlessSpan
+		return ret
+	}
+	if exception := lastException(evt); exception > last {
+		last = exception
+	}
+	// every occurrence ends within the longest duration after the latest start, a day more
+	// as wall clock is off the instant by hours
+	duration := evt.End - evt.Start
+	for _, override := range evt.Overrides {
+		if override.End-override.Start > duration {
+			duration = override.End - override.Start
+		}
+	}
+	if end := last + duration + 24*3600; end > ret.End {
+		ret.End = end
+	}
+	return ret
+}
+
+// deep copy, so the store never shares memory with callers
+func cloneEvent(evt Event) Event {
+	if evt.Attendees != nil {
+		evt.Attendees = append([]Attendee(nil), evt.Attendees...)
+	}
+	evt.Metadata = copyMetadata(evt.Metadata)
+	if evt.Recurrence != nil {
+		rule := *evt.Recurrence
+		rule.ByDay = append([]WeekdayNum(nil), rule.ByDay...)
+		rule.ByMonthDay = append([]int(nil), rule.ByMonthDay...)
+		rule.ByMonth = append([]time.Month(nil), rule.ByMonth...)
+		rule.BySetPos = append([]int(nil), rule.BySetPos...)
+		evt.Recurrence = &rule
+	}
+	if evt.RDates != nil {
+		evt.RDates = append([]time.Time(nil), evt.RDates...)
+	}
+	if evt.ExDates != nil {
+		evt.ExDates = append([]time.Time(nil), evt.ExDates...)
+	}
+	if evt.Overrides != nil {
+		overrides := make(map[int64]Event, len(evt.Overrides))
+		for recurrenceId, override := range evt.Overrides {
+			overrides[recurrenceId] = cloneEvent(override)
+		}
+		evt.Overrides = overrides
+	}
+	return evt
+}
+
+// store evt as it is without checking conflicts, used when loading events checked before
+func (c *MemoryStore) restore(evt Event) {
+	span := eventSpan(&evt, c.opts.zone())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(evt, span)
+}
+
+// delete the event if it exists
+func (c *MemoryStore) remove(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unset(id)
+}
+
+func (c *MemoryStore) set(evt Event, span CalendarEvent) {
+	evt = cloneEvent(evt)
+	span.Id = evt.Id
+	c.events[evt.Id] = &evt
+	c.spans.Insert(span)
+	if evt.Id >= c.nextId {
+		c.nextId = evt.Id + 1
+	}
+}
+
+func (c *MemoryStore) unset(id int) {
+	delete(c.events, id)
+	c.spans.Delete(id)
+}
+
+// every event ordered by id and the next id to use
+func (c *MemoryStore) dump() (Events, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ret := make(Events, 0, len(c.events))
+	for _, evt := range c.events {
+		ret = append(ret, cloneEvent(*evt))
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Id < ret[j].Id
+	})
+	return ret, c.nextId
+}
@@ -0,0 +1,334 @@
+package calendar
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(StoreOptions{})
+	var _ Store = store
+	standup := NewZonedEvent(99, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 15, 0, 0, time.UTC))
+	standup.Title = "Standup"
+	standup.Attendees = []Attendee{{Name: "Ann", Email: "ann@example.com"}}
+	created, conflicts, err := store.Create(standup)
+	if err != nil || created.Id != 0 || len(conflicts) != 0 {
+		t.Logf("create failed: %v %v", created, err)
+		t.FailNow()
+	}
+	// the store keeps its own copy
+	standup.Attendees[0].Name = "Bob"
+	if got, err := store.Get(0); err != nil || got.Attendees[0].Name != "Ann" {
+		t.Logf("store changed by the caller: %v %v", got, err)
+		t.FailNow()
+	}
+
+	review := NewZonedEvent(0, time.Date(2026, 10, 19, 9, 10, 0, 0, time.UTC), time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	created, conflicts, err = store.Create(review)
+	if err != nil || created.Id != 1 || !reflect.DeepEqual(conflicts, []int{0}) {
+		t.Logf("expect conflict with 0 but got %v %v", conflicts, err)
+		t.FailNow()
+	}
+	review = created
+	review.Start += 3600
+	review.End += 3600
+	if _, conflicts, err := store.Update(review); err != nil || len(conflicts) != 0 {
+		t.Logf("moved review should not conflict: %v %v", conflicts, err)
+		t.FailNow()
+	}
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC).Unix()
+	evts, err := store.List(day, day+24*3600-1)
+	if err != nil || len(evts) != 2 || evts[0].Title != "Standup" || evts[1].Id != 1 {
+		t.Logf("wrong list %v %v", evts, err)
+		t.FailNow()
+	}
+	if evts, _ := store.List(day+9*3600+15*60+1, day+10*3600-1); len(evts) != 0 {
+		t.Logf("nothing between the events but got %v", evts)
+		t.FailNow()
+	}
+
+	if err := store.Delete(0, 0); err != nil {
+		t.Logf("delete failed: %s", err)
+		t.FailNow()
+	}
+	if _, err := store.Get(0); errors.Cause(err) != ErrNotFound {
+		t.Logf("expect not found but got %v", err)
+		t.FailNow()
+	}
+	if err := store.Delete(0, 0); errors.Cause(err) != ErrNotFound {
+		t.Logf("expect not found but got %v", err)
+		t.FailNow()
+	}
+	if _, _, err := store.Update(standup); errors.Cause(err) != ErrNotFound {
+		t.Logf("expect not found but got %v", err)
+		t.FailNow()
+	}
+	if _, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, 10, 5}}); errors.Cause(err) != errorInvalidEvent {
+		t.Logf("expect invalid event but got %v", err)
+		t.FailNow()
+	}
+	// ids are never reused
+	if created, _, _ := store.Create(standup); created.Id != 2 || store.Len() != 2 {
+		t.Logf("expect id 2 but got %d", created.Id)
+		t.FailNow()
+	}
+}
+
+func TestMemoryStore_Reject(t *testing.T) {
+	store := NewMemoryStore(StoreOptions{Conflicts: ConflictReject, Boundary: BoundaryHalfOpen})
+	if _, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, 0, 100}}); err != nil {
+		t.Logf("create failed: %s", err)
+		t.FailNow()
+	}
+	// touching is fine for half open events
+	if _, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, 100, 200}}); err != nil {
+		t.Logf("create failed: %s", err)
+		t.FailNow()
+	}
+	_, conflicts, err := store.Create(Event{CalendarEvent: CalendarEvent{0, 50, 150}})
+	if errors.Cause(err) != ErrOverlap || !reflect.DeepEqual(conflicts, []int{0, 1}) {
+		t.Logf("expect overlap with 0 and 1 but got %v %v", conflicts, err)
+		t.FailNow()
+	}
+	if _, _, err := store.Update(Event{CalendarEvent: CalendarEvent{1, 90, 200}}); errors.Cause(err) != ErrOverlap {
+		t.Logf("expect overlap but got %v", err)
+		t.FailNow()
+	}
+	// a rejected write changes nothing
+	if evt, _ := store.Get(1); evt.Start != 100 || store.Len() != 2 {
+		t.Logf("rejected write was stored: %v", evt)
+		t.FailNow()
+	}
+	// all-day events are ignored unless asked for
+	holiday := NewAllDayEvent(0, Date{1970, 1, 1}, Date{1970, 1, 1})
+	if _, _, err := store.Create(holiday); err != nil {
+		t.Logf("all-day event should not conflict: %s", err)
+		t.FailNow()
+	}
+}
+
+func TestMemoryStore_Series(t *testing.T) {
+	store := NewMemoryStore(StoreOptions{Conflicts: ConflictReject})
+	rule, _ := ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=MO")
+	standup := NewZonedEvent(0, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 15, 0, 0, time.UTC))
+	standup.Recurrence = rule
+	if _, _, err := store.Create(standup); err != nil {
+		t.Logf("create failed: %s", err)
+		t.FailNow()
+	}
+	tuesday := NewZonedEvent(0, time.Date(2026, 12, 1, 9, 0, 0, 0, time.UTC), time.Date(2026, 12, 1, 10, 0, 0, 0, time.UTC))
+	if _, _, err := store.Create(tuesday); err != nil {
+		t.Logf("tuesday should not conflict: %s", err)
+		t.FailNow()
+	}
+	monday := NewZonedEvent(0, time.Date(2027, 3, 1, 9, 10, 0, 0, time.UTC), time.Date(2027, 3, 1, 10, 0, 0, 0, time.UTC))
+	if _, conflicts, err := store.Create(monday); errors.Cause(err) != ErrOverlap || !reflect.DeepEqual(conflicts, []int{0}) {
+		t.Logf("monday should conflict with the series: %v %v", conflicts, err)
+		t.FailNow()
+	}
+	// another endless series on mondays
+	review := NewZonedEvent(0, time.Date(2026, 10, 26, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 26, 9, 30, 0, 0, time.UTC))
+	review.Recurrence = rule
+	if _, _, err := store.Create(review); errors.Cause(err) != ErrOverlap {
+		t.Logf("series should conflict: %v", err)
+		t.FailNow()
+	}
+
+	// the series is listed only when an occurrence is in range
+	monday0 := time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC).Unix()
+	if evts, _ := store.List(monday0, monday0+24*3600-1); len(evts) != 1 || evts[0].Id != 0 {
+		t.Logf("expect the series but got %v", evts)
+		t.FailNow()
+	}
+	if evts, _ := store.List(monday0+24*3600, monday0+2*24*3600-1); len(evts) != 0 {
+		t.Logf("expect nothing on tuesday but got %v", evts)
+		t.FailNow()
+	}
+}
+
+func TestMemoryStore_SeriesBeyondHorizon(t *testing.T) {
+	var logged []string
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	store := NewMemoryStore(StoreOptions{Conflicts: ConflictReject, Logf: logf})
+	// every 9 weeks and every 10 weeks a week later, first on the same monday after 81 weeks
+	nine := NewZonedEvent(0, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	nine.Recurrence, _ = ParseRecurrenceRule("FREQ=WEEKLY;INTERVAL=9")
+	ten := NewZonedEvent(0, time.Date(2026, 10, 26, 9, 30, 0, 0, time.UTC), time.Date(2026, 10, 26, 10, 30, 0, 0, time.UTC))
+	ten.Recurrence, _ = ParseRecurrenceRule("FREQ=WEEKLY;INTERVAL=10")
+	store.Create(nine)
+	if _, conflicts, err := store.Create(ten); errors.Cause(err) != ErrOverlap || !reflect.DeepEqual(conflicts, []int{0}) {
+		t.Logf("expect the collision after 81 weeks but got %v %v", conflicts, err)
+		t.FailNow()
+	}
+	ten.Start += 3600
+	ten.End += 3600
+	if _, _, err := store.Create(ten); err != nil || len(logged) != 0 {
+		t.Logf("series apart should be stored: %v %v", err, logged)
+		t.FailNow()
+	}
+
+	// monthly rules do not repeat within weeks, so the check is cut at the horizon and told
+	monthly := NewZonedEvent(0, time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 13, 0, 0, 0, time.UTC))
+	monthly.Recurrence, _ = ParseRecurrenceRule("FREQ=MONTHLY")
+	if _, _, err := store.Create(monthly); err != nil || len(logged) != 2 || !strings.Contains(logged[0], "event 2 and 0 are checked until 2027-11-02") {
+		t.Logf("expect the cut told but got %v %v", err, logged)
+		t.FailNow()
+	}
+}
+
+// an endless series is not expanded over the whole window, the read lock would block every writer
+func TestMemoryStore_ListEndless(t *testing.T) {
+	store := NewMemoryStore(StoreOptions{})
+	rule, _ := ParseRecurrenceRule("FREQ=DAILY")
+	daily := NewZonedEvent(0, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 15, 0, 0, time.UTC))
+	daily.Recurrence = rule
+	// the third one moved a month ahead
+	third := time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC).Unix()
+	daily.Override(third, NewZonedEvent(0, time.Date(2026, 11, 21, 20, 0, 0, 0, time.UTC), time.Date(2026, 11, 21, 21, 0, 0, 0, time.UTC)))
+	store.Create(daily)
+
+	listed := make(chan Events, 1)
+	go func() {
+		evts, _ := store.List(0, 1<<62)
+		listed <- evts
+	}()
+	select {
+	case evts := <-listed:
+		if len(evts) != 1 {
+			t.Logf("expect the series but got %v", evts)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Logf("list of a wide window should not expand the series")
+		t.FailNow()
+	}
+	// only the moved occurrence is in the evening
+	evening := time.Date(2026, 10, 21, 20, 0, 0, 0, time.UTC).Unix()
+	if evts, _ := store.List(evening, evening+3600); len(evts) != 0 {
+		t.Logf("expect nothing but got %v", evts)
+		t.FailNow()
+	}
+	evening = time.Date(2026, 11, 21, 20, 30, 0, 0, time.UTC).Unix()
+	if evts, _ := store.List(evening, evening+60); len(evts) != 1 {
+		t.Logf("expect the moved occurrence but got %v", evts)
+		t.FailNow()
+	}
+}
+
+// series with a large COUNT or far UNTIL are not expanded for their span, so a write is quick
+// and the occurrences far ahead still conflict
+func TestMemoryStore_LongSeries(t *testing.T) {
+	store := NewMemoryStore(StoreOptions{Conflicts: ConflictReject})
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	for i, value := range []string{"FREQ=DAILY;COUNT=200000", "FREQ=DAILY;UNTIL=29991231T000000Z"} {
+		evt := NewZonedEvent(0, start.Add(time.Duration(i)*time.Hour), start.Add(time.Duration(i)*time.Hour+30*time.Minute))
+		evt.Recurrence, _ = ParseRecurrenceRule(value)
+		created := make(chan error, 1)
+		go func() {
+			_, _, err := store.Create(evt)
+			created <- err
+		}()
+		select {
+		case err := <-created:
+			if err != nil {
+				t.Logf("create %s failed: %s", value, err)
+				t.FailNow()
+			}
+		case <-time.After(5 * time.Second):
+			t.Logf("create %s should not expand the series", value)
+			t.FailNow()
+		}
+		// spanned as endless instead of walking the rule to its end
+		if span, _ := store.spans.Get(i); span.End != endlessSpan {
+			t.Logf("span of %s should be endless instead of %s", value, span.ToString())
+			t.FailNow()
+		}
+	}
+	// past the walk of the rule for the span
+	far := start.AddDate(0, 0, 5000)
+	if _, conflicts, err := store.Create(NewZonedEvent(0, far, far.Add(2*time.Hour))); errors.Cause(err) != ErrOverlap || !reflect.DeepEqual(conflicts, []int{0, 1}) {
+		t.Logf("expect conflicts with both series but got %v %v", conflicts, err)
+		t.FailNow()
+	}
+	if evts, _ := store.List(far.Add(-time.Hour).Unix(), far.Unix()); len(evts) != 1 || evts[0].Id != 0 {
+		t.Logf("expect the count series but got %v", evts)
+		t.FailNow()
+	}
+
+	// a short series ends with its last occurrence
+	short := NewZonedEvent(0, start.Add(4*time.Hour), start.Add(5*time.Hour))
+	short.Recurrence, _ = ParseRecurrenceRule("FREQ=DAILY;COUNT=3")
+	store.Create(short)
+	third := start.AddDate(0, 0, 2).Add(4 * time.Hour)
+	if _, conflicts, err := store.Create(NewZonedEvent(0, third, third.Add(time.Hour))); errors.Cause(err) != ErrOverlap || !reflect.DeepEqual(conflicts, []int{2}) {
+		t.Logf("expect a conflict with the third occurrence but got %v %v", conflicts, err)
+		t.FailNow()
+	}
+	fourth := third.AddDate(0, 0, 1)
+	if _, _, err := store.Create(NewZonedEvent(0, fourth, fourth.Add(time.Hour))); err != nil {
+		t.Logf("there is no fourth occurrence: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := NewMemoryStore(StoreOptions{})
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				start := int64(worker*100000 + i*100)
+				evt, _, err := store.Create(Event{CalendarEvent: CalendarEvent{0, start, start + 150}, Metadata: map[string]string{"worker": "x"}})
+				if err != nil {
+					t.Errorf("create failed: %s", err)
+					return
+				}
+				evt.End += 10
+				evt.Metadata["worker"] = "y"
+				if _, _, err := store.Update(evt); err != nil {
+					t.Errorf("update failed: %s", err)
+					return
+				}
+				if _, err := store.Get(evt.Id); err != nil {
+					t.Errorf("get failed: %s", err)
+					return
+				}
+				if _, err := store.List(start-1000, start+1000); err != nil {
+					t.Errorf("list failed: %s", err)
+					return
+				}
+				if i%2 == 0 {
+					if err := store.Delete(evt.Id, 0); err != nil {
+						t.Errorf("delete failed: %s", err)
+						return
+					}
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	if store.Len() != 8*100 {
+		t.Logf("expect 800 events but got %d", store.Len())
+		t.FailNow()
+	}
+	evts, _ := store.List(0, 1<<40)
+	seen := make(map[int]bool)
+	for _, evt := range evts {
+		if seen[evt.Id] || evt.End-evt.Start != 160 || evt.Metadata["worker"] != "y" {
+			t.Logf("wrong event %v", evt)
+			t.FailNow()
+		}
+		seen[evt.Id] = true
+	}
+}
@@ -0,0 +1,152 @@
+package calendar
+
+import (
+	"container/heap"
+
+	"github.com/pkg/errors"
+)
+
+// returned when streamed events are not sorted by Start
+var ErrOutOfOrder = errors.New("event out of order")
+
+// active events ordered by End, so the ones already finished are evicted first
+type endHeap []CalendarEvent
+
+func (c endHeap) Len() int {
+	return len(c)
+}
+
+func (c endHeap) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+func (c endHeap) Less(i, j int) bool {
+	return c[i].End < c[j].End
+}
+
+func (c *endHeap) Push(x interface{}) {
+	*c = append(*c, x.(CalendarEvent))
+}
+
+func (c *endHeap) Pop() interface{} {
+	old := *c
+	evt := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return evt
+}
+
+// overlapStream keeps only the events which may still overlap the upcoming ones
+// memory is bounded by the max number of concurrent events
+type overlapStream struct {
+	mode    BoundaryMode
+	active  endHeap
+	last    CalendarEvent
+	started bool
+}
+
+func (c *overlapStream) push(evt CalendarEvent) (ret []CalendarPair, err error) {
+	// reuse the ordering of CalendarEvents
+	if c.started && (CalendarEvents{c.last, evt}).Less(1, 0) {
+		err = errors.Wrapf(ErrOutOfOrder, "event %d starts at %d before event %d at %d", evt.Id, evt.Start, c.last.Id, c.last.Start)
+		return
+	}
+	c.last = evt
+	c.started = true
+
+	// empty events never overlap, see BoundaryMode
+	closed, ok := c.mode.Closed(evt)
+	if !ok {
+		return
+	}
+	// anything ended before this start can never overlap again
+	for len(c.active) > 0 && c.active[0].End < closed.Start {
+		heap.Pop(&c.active)
+	}
+	for _, other := range c.active {
+		ret = append(ret, newCalendarPair(other.Id, evt.Id))
+	}
+	heap.Push(&c.active, closed)
+	return
+}
+
+// read events sorted by Start from in and send every overlap pair, FirstId always smaller
+// both channels are closed when in is closed, if an event is out of order the error is sent
+// and the rest of in is drained without checking so the producer would not be blocked
+func StreamOverlaps(in <-chan CalendarEvent) (<-chan CalendarPair, <-chan error) {
+	return StreamOverlapsMode(in, BoundaryClosed)
+}
+
+// same as StreamOverlaps but events are treated in given boundary mode
+func StreamOverlapsMode(in <-chan CalendarEvent, mode BoundaryMode) (<-chan CalendarPair, <-chan error) {
+	out := make(chan CalendarPair)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		stream := &overlapStream{mode: mode}
+		for evt := range in {
+			pairs, err := stream.push(evt)
+			if err != nil {
+				errs <- err
+				for range in {
+				}
+				return
+			}
+			for _, pair := range pairs {
+				out <- pair
+			}
+		}
+	}()
+	return out, errs
+}
+
+// OverlapIterator is the pull style version of StreamOverlaps
+//
+//	it := NewOverlapIterator(next)
+//	for it.Next() {
+//		pair := it.Pair()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type OverlapIterator struct {
+	next    func() (CalendarEvent, bool)
+	stream  overlapStream
+	pending []CalendarPair
+	pair    CalendarPair
+	err     error
+}
+
+// next should return events sorted by Start, and false when there is no more
+func NewOverlapIterator(next func() (CalendarEvent, bool)) *OverlapIterator {
+	return NewOverlapIteratorMode(next, BoundaryClosed)
+}
+
+// same as NewOverlapIterator but events are treated in given boundary mode
+func NewOverlapIteratorMode(next func() (CalendarEvent, bool), mode BoundaryMode) *OverlapIterator {
+	return &OverlapIterator{next: next, stream: overlapStream{mode: mode}}
+}
+
+// advance to next pair, return false when the events run out or an error happened
+func (c *OverlapIterator) Next() bool {
+	for len(c.pending) == 0 {
+		if c.err != nil {
+			return false
+		}
+		evt, ok := c.next()
+		if !ok {
+			return false
+		}
+		c.pending, c.err = c.stream.push(evt)
+	}
+	c.pair = c.pending[0]
+	c.pending = c.pending[1:]
+	return true
+}
+
+func (c *OverlapIterator) Pair() CalendarPair {
+	return c.pair
+}
+
+func (c *OverlapIterator) Err() error {
+	return c.err
+}
@@ -0,0 +1,121 @@
+package calendar
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// same as compareTwoPairArrays but in O(n) for large number of pairs
+func isSamePairs(left, right []CalendarPair) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	counts := make(map[CalendarPair]int, len(left))
+	for _, pair := range left {
+		counts[pair]++
+	}
+	for _, pair := range right {
+		if counts[pair] == 0 {
+			return false
+		}
+		counts[pair]--
+	}
+	return true
+}
+
+func sortedTestEvents() CalendarEvents {
+	events := make(CalendarEvents, len(testEvents))
+	copy(events, testEvents)
+	sort.Stable(events)
+	return events
+}
+
+func TestStreamOverlaps(t *testing.T) {
+	events := sortedTestEvents()
+	in := make(chan CalendarEvent)
+	go func() {
+		for _, evt := range events {
+			in <- evt
+		}
+		close(in)
+	}()
+
+	out, errs := StreamOverlaps(in)
+	var ret []CalendarPair
+	for pair := range out {
+		ret = append(ret, pair)
+	}
+	if err := <-errs; err != nil {
+		t.Logf("stream should not fail: %s", err)
+		t.FailNow()
+	}
+	baseline := FindOverlapPairsBrutal(events)
+	if !isSamePairs(ret, baseline) {
+		t.Log("stream pairs are different from baseline")
+		t.FailNow()
+	}
+}
+
+func TestStreamOverlaps_OutOfOrder(t *testing.T) {
+	in := make(chan CalendarEvent, 4)
+	in <- CalendarEvent{0, 100, 200}
+	in <- CalendarEvent{1, 150, 160}
+	in <- CalendarEvent{2, 50, 60}
+	in <- CalendarEvent{3, 300, 400}
+	close(in)
+
+	out, errs := StreamOverlaps(in)
+	var ret []CalendarPair
+	for pair := range out {
+		ret = append(ret, pair)
+	}
+	if len(ret) != 1 || ret[0] != (CalendarPair{0, 1}) {
+		t.Logf("it should return pair 0, 1 before failing instead of %v", ret)
+		t.FailNow()
+	}
+	if err := <-errs; errors.Cause(err) != ErrOutOfOrder {
+		t.Logf("it should fail with out of order instead of %v", err)
+		t.FailNow()
+	}
+}
+
+func TestOverlapIterator(t *testing.T) {
+	events := sortedTestEvents()
+	idx := 0
+	it := NewOverlapIterator(func() (CalendarEvent, bool) {
+		if idx >= len(events) {
+			return CalendarEvent{}, false
+		}
+		idx++
+		return events[idx-1], true
+	})
+	var ret []CalendarPair
+	for it.Next() {
+		ret = append(ret, it.Pair())
+	}
+	if it.Err() != nil {
+		t.Logf("iterator should not fail: %s", it.Err())
+		t.FailNow()
+	}
+	baseline := FindOverlapPairsBrutal(events)
+	if !isSamePairs(ret, baseline) {
+		t.Log("iterator pairs are different from baseline")
+		t.FailNow()
+	}
+
+	events = CalendarEvents{{0, 100, 200}, {1, 50, 60}}
+	idx = 0
+	it = NewOverlapIterator(func() (CalendarEvent, bool) {
+		if idx >= len(events) {
+			return CalendarEvent{}, false
+		}
+		idx++
+		return events[idx-1], true
+	})
+	if it.Next() || errors.Cause(it.Err()) != ErrOutOfOrder {
+		t.Logf("it should fail with out of order instead of %v", it.Err())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,78 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// returned by stores when the version given to match is not the stored one
+// stores may wrap it, use errors.Cause to get it back
+type VersionConflictError struct {
+	Id int
+	// version given by the caller
+	Expected int64
+	// version in the store
+	Actual int64
+}
+
+func (c *VersionConflictError) Error() string {
+	return fmt.Sprintf("event %d is at version %d, not %d", c.Id, c.Actual, c.Expected)
+}
+
+var errorInvalidETag = errors.New("invalid etag")
+
+// strong entity tag of the version, e.g. "3" with the quotes
+func (c *Event) ETag() string {
+	return strconv.Quote(strconv.FormatInt(c.Version, 10))
+}
+
+// the version of an entity tag made by Event.ETag
+func ParseETag(tag string) (int64, error) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errors.Wrapf(errorInvalidETag, "%q", tag)
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errors.Wrapf(errorInvalidETag, "%q", tag)
+	}
+	return version, nil
+}
+
+type BatchKind int
+
+const (
+	BatchCreate BatchKind = iota
+	BatchUpdate
+	BatchDelete
+)
+
+func (c BatchKind) String() string {
+	switch c {
+	case BatchCreate:
+		return "create"
+	case BatchUpdate:
+		return "update"
+	case BatchDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("BatchKind(%d)", int(c))
+}
+
+// one write of Store.Batch
+// Event.Version is matched by update and delete unless it is 0, delete only uses Id and Version
+type BatchOp struct {
+	Kind  BatchKind
+	Event Event
+}
+
+// what the op would return on its own, Event is empty for delete
+type BatchResult struct {
+	Event     Event
+	Conflicts []int
+}
+
+var errorInvalidBatch = errors.New("invalid batch")
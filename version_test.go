@@ -0,0 +1,165 @@
+package calendar
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestETag(t *testing.T) {
+	evt := Event{Version: 12}
+	if evt.ETag() != `"12"` {
+		t.Logf("wrong etag %s", evt.ETag())
+		t.FailNow()
+	}
+	if version, err := ParseETag(` "12" `); err != nil || version != 12 {
+		t.Logf("expect 12 but got %d %v", version, err)
+		t.FailNow()
+	}
+	for _, tag := range []string{`12`, `W/"12"`, `"x"`, `"0"`, `"`} {
+		if _, err := ParseETag(tag); errors.Cause(err) != errorInvalidETag {
+			t.Logf("%s should be invalid but got %v", tag, err)
+			t.FailNow()
+		}
+	}
+	data, _ := json.Marshal(Event{CalendarEvent: CalendarEvent{1, 0, 10}, Version: 3})
+	var ret Event
+	if err := json.Unmarshal(data, &ret); err != nil || ret.Version != 3 {
+		t.Logf("version lost in %s %v", data, err)
+		t.FailNow()
+	}
+}
+
+func TestStore_Versions(t *testing.T) {
+	for _, store := range []Store{NewMemoryStore(StoreOptions{}), openFileStore(t, t.TempDir(), FileStoreOptions{})} {
+		created, _, _ := store.Create(Event{CalendarEvent: CalendarEvent{0, 0, 100}, Version: 9})
+		if created.Version != 1 {
+			t.Logf("expect version 1 but got %d", created.Version)
+			t.FailNow()
+		}
+		// two writers read version 1, the second one loses
+		first, second := created, created
+		first.Title = "First"
+		updated, _, err := store.Update(first)
+		if err != nil || updated.Version != 2 || updated.Title != "First" {
+			t.Logf("update failed: %v %v", updated, err)
+			t.FailNow()
+		}
+		second.Title = "Second"
+		_, _, err = store.Update(second)
+		conflict, ok := errors.Cause(err).(*VersionConflictError)
+		if !ok || conflict.Id != created.Id || conflict.Expected != 1 || conflict.Actual != 2 {
+			t.Logf("expect version conflict but got %v", err)
+			t.FailNow()
+		}
+		if evt, _ := store.Get(created.Id); evt.Title != "First" || evt.Version != 2 {
+			t.Logf("stale write was stored: %v", evt)
+			t.FailNow()
+		}
+		// version 0 matches any
+		second.Version = 0
+		if updated, _, err := store.Update(second); err != nil || updated.Version != 3 {
+			t.Logf("unconditional update failed: %v %v", updated, err)
+			t.FailNow()
+		}
+
+		if err := store.Delete(created.Id, 2); errors.Cause(err) == nil {
+			t.Logf("stale delete should fail")
+			t.FailNow()
+		} else if _, ok := errors.Cause(err).(*VersionConflictError); !ok {
+			t.Logf("expect version conflict but got %v", err)
+			t.FailNow()
+		}
+		if err := store.Delete(created.Id, 3); err != nil {
+			t.Logf("delete failed: %s", err)
+			t.FailNow()
+		}
+		if err := store.Delete(created.Id, 3); errors.Cause(err) != ErrNotFound {
+			t.Logf("expect not found but got %v", err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestStore_Batch(t *testing.T) {
+	dir := t.TempDir()
+	opts := FileStoreOptions{StoreOptions: StoreOptions{Conflicts: ConflictReject}, SnapshotEvery: -1}
+	file := openFileStore(t, dir, opts)
+	for _, store := range []Store{NewMemoryStore(opts.StoreOptions), file} {
+		a, _, _ := store.Create(Event{CalendarEvent: CalendarEvent{0, 0, 100}, Title: "A"})
+		b, _, _ := store.Create(Event{CalendarEvent: CalendarEvent{0, 200, 300}, Title: "B"})
+		before := storeState(t, store)
+
+		// the last op is stale, so nothing happens
+		a.Title = "A2"
+		_, err := store.Batch([]BatchOp{
+			{BatchUpdate, a},
+			{BatchCreate, Event{CalendarEvent: CalendarEvent{0, 400, 500}}},
+			{BatchDelete, Event{CalendarEvent: CalendarEvent{Id: b.Id}, Version: 5}},
+		})
+		if _, ok := errors.Cause(err).(*VersionConflictError); !ok {
+			t.Logf("expect version conflict but got %v", err)
+			t.FailNow()
+		}
+		if state := storeState(t, store); !reflect.DeepEqual(state, before) {
+			t.Logf("failed batch changed %v to %v", before, state)
+			t.FailNow()
+		}
+		// ops see the ones before them, moving A away lets C take its place
+		a.Start, a.End = 1000, 1100
+		results, err := store.Batch([]BatchOp{
+			{BatchUpdate, a},
+			{BatchCreate, Event{CalendarEvent: CalendarEvent{0, 50, 150}, Title: "C"}},
+			{BatchDelete, b},
+		})
+		if err != nil || len(results) != 3 || results[0].Event.Version != 2 || results[1].Event.Id != 2 || results[1].Event.Version != 1 {
+			t.Logf("batch failed: %v %v", results, err)
+			t.FailNow()
+		}
+		if _, err := store.Get(b.Id); errors.Cause(err) != ErrNotFound {
+			t.Logf("B should be deleted: %v", err)
+			t.FailNow()
+		}
+		// overlap rejection fails the batch as well
+		_, err = store.Batch([]BatchOp{{BatchCreate, Event{CalendarEvent: CalendarEvent{0, 5000, 5100}}}, {BatchCreate, Event{CalendarEvent: CalendarEvent{0, 60, 70}}}})
+		if errors.Cause(err) != ErrOverlap {
+			t.Logf("expect overlap but got %v", err)
+			t.FailNow()
+		}
+		if _, err := store.Batch([]BatchOp{{BatchKind(7), Event{}}}); errors.Cause(err) != errorInvalidBatch {
+			t.Logf("expect invalid batch but got %v", err)
+			t.FailNow()
+		}
+	}
+
+	// the batch is one record of the log
+	expect := storeState(t, file)
+	file.Close()
+	data, _ := os.ReadFile(filepath.Join(dir, walFile))
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		t.Logf("log should end with a record")
+		t.FailNow()
+	}
+	reopened := openFileStore(t, dir, opts)
+	if state := storeState(t, reopened); !reflect.DeepEqual(state, expect) {
+		t.Logf("expect %v but got %v", expect, state)
+		t.FailNow()
+	}
+	if evt, _ := reopened.Get(0); evt.Version != 2 {
+		t.Logf("version lost on reopen %v", evt)
+		t.FailNow()
+	}
+	reopened.Close()
+	// a torn batch is dropped as a whole
+	os.WriteFile(filepath.Join(dir, walFile), data[:len(data)-10], 0644)
+	reopened = openFileStore(t, dir, opts)
+	if state := storeState(t, reopened); len(state) != 2 || state[1] == "" {
+		t.Logf("torn batch should leave A and B: %v", state)
+		t.FailNow()
+	}
+	reopened.Close()
+}
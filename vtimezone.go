@@ -0,0 +1,363 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// rules of VTIMEZONE are expanded until this year, the last offset is kept after it
+const vtimezoneHorizon = 2100
+
+// locations built from TZif data which is not in the IANA database, e.g. from VTIMEZONE,
+// the data is kept so events in them are encoded with every DST rule, see ZoneData of Event JSON
+var customZones = struct {
+	sync.Mutex
+	data      map[*time.Location][]byte
+	locations map[string]*time.Location
+}{data: make(map[*time.Location][]byte), locations: make(map[string]*time.Location)}
+
+// same name and data gives the same location
+func loadCustomZone(name string, data []byte) (*time.Location, error) {
+	key := name + "\x00" + string(data)
+	customZones.Lock()
+	defer customZones.Unlock()
+	if loc, ok := customZones.locations[key]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocationFromTZData(name, data)
+	if err != nil {
+		return nil, err
+	}
+	customZones.data[loc] = data
+	customZones.locations[key] = loc
+	return loc, nil
+}
+
+// TZif data of a location from loadCustomZone, nil for others
+func customZoneData(loc *time.Location) []byte {
+	customZones.Lock()
+	defer customZones.Unlock()
+	return customZones.data[loc]
+}
+
+// VTIMEZONE is a list of STANDARD and DAYLIGHT observances
+type vtimezone struct {
+	observances []vtimezoneObservance
+}
+
+type vtimezoneObservance struct {
+	daylight bool
+	name     string
+	// wall clock in UTC in the offset before the onset
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	rule       *RecurrenceRule
+	rdates     []time.Time
+}
+
+// offset of a zone starting at some instant
+type zoneTransition struct {
+	at       int64
+	offset   int
+	daylight bool
+	name     string
+}
+
+func parseVTimezone(component *icsComponent) (*vtimezone, error) {
+	zone := &vtimezone{}
+	for _, child := range component.Children {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+		observance := vtimezoneObservance{daylight: child.Name == "DAYLIGHT"}
+		dtstart := child.get("DTSTART")
+		from := child.get("TZOFFSETFROM")
+		to := child.get("TZOFFSETTO")
+		if dtstart == nil || from == nil || to == nil {
+			return nil, parseErrorf(child.Line, "%s needs DTSTART, TZOFFSETFROM and TZOFFSETTO", child.Name)
+		}
+		var err error
+		if observance.start, err = time.Parse("20060102T150405", dtstart.Value); err != nil {
+			return nil, parseErrorf(dtstart.Line, "invalid DTSTART %q of %s", dtstart.Value, child.Name)
+		}
+		if observance.offsetFrom, err = parseUTCOffset(from.Value); err != nil {
+			return nil, &ParseError{from.Line, err}
+		}
+		if observance.offsetTo, err = parseUTCOffset(to.Value); err != nil {
+			return nil, &ParseError{to.Line, err}
+		}
+		if name := child.get("TZNAME"); name != nil {
+			observance.name = unescapeICSText(name.Value)
+		}
+		if rrule := child.get("RRULE"); rrule != nil {
+			if observance.rule, err = ParseRecurrenceRule(rrule.Value); err != nil {
+				return nil, &ParseError{rrule.Line, err}
+			}
+		}
+		for _, rdate := range child.all("RDATE") {
+			for _, value := range strings.Split(rdate.Value, ",") {
+				t, err := time.Parse("20060102T150405", value)
+				if err != nil {
+					return nil, parseErrorf(rdate.Line, "invalid RDATE %q", value)
+				}
+				observance.rdates = append(observance.rdates, t)
+			}
+		}
+		zone.observances = append(zone.observances, observance)
+	}
+	if len(zone.observances) == 0 {
+		return nil, parseErrorf(component.Line, "VTIMEZONE without STANDARD or DAYLIGHT")
+	}
+	return zone, nil
+}
+
+// parse +HHMM or -HHMMSS into seconds
+func parseUTCOffset(value string) (int, error) {
+	if len(value) != 5 && len(value) != 7 || value[0] != '+' && value[0] != '-' {
+		return 0, errors.Errorf("invalid UTC offset %q", value)
+	}
+	hour, err1 := strconv.Atoi(value[1:3])
+	minute, err2 := strconv.Atoi(value[3:5])
+	second := 0
+	var err3 error
+	if len(value) == 7 {
+		second, err3 = strconv.Atoi(value[5:7])
+	}
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, errors.Errorf("invalid UTC offset %q", value)
+	}
+	ret := hour*3600 + minute*60 + second
+	if value[0] == '-' {
+		ret = -ret
+	}
+	return ret, nil
+}
+
+// format seconds as +HHMM, or +HHMMSS when there are seconds
+func formatUTCOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	if offset%60 != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, offset/3600, offset/60%60, offset%60)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+}
+
+// every onset of every observance in order, first is the zone before the earliest onset
+func (c *vtimezone) transitions() (first zoneTransition, ret []zoneTransition) {
+	for i := range c.observances {
+		observance := &c.observances[i]
+		add := func(wall time.Time) {
+			ret = append(ret, zoneTransition{
+				at:       wall.Unix() - int64(observance.offsetFrom),
+				offset:   observance.offsetTo,
+				daylight: observance.daylight,
+				name:     observance.name,
+			})
+		}
+		add(observance.start)
+		if rule := observance.rule; rule != nil {
+			count := 1
+			rule.each(observance.start, func(wall time.Time) bool {
+				if wall.Equal(observance.start) {
+					return true
+				}
+				at := wall.Unix() - int64(observance.offsetFrom)
+				if wall.Year() > vtimezoneHorizon || rule.Count > 0 && count >= rule.Count ||
+					!rule.Until.IsZero() && at > rule.Until.Unix() {
+					return false
+				}
+				count++
+				add(wall)
+				return true
+			})
+		}
+		for _, rdate := range observance.rdates {
+			add(rdate)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].at < ret[j].at
+	})
+
+	// the zone before is the observance switched to the offset the earliest one switches from
+	earliest := c.observances[0]
+	for _, observance := range c.observances {
+		if observance.start.Unix()-int64(observance.offsetFrom) < earliest.start.Unix()-int64(earliest.offsetFrom) {
+			earliest = observance
+		}
+	}
+	first = zoneTransition{offset: earliest.offsetFrom}
+	for _, observance := range c.observances {
+		if observance.offsetTo == earliest.offsetFrom {
+			first.daylight, first.name = observance.daylight, observance.name
+			break
+		}
+	}
+
+	// drop the onsets not changing anything
+	current := first
+	kept := ret[:0]
+	for _, transition := range ret {
+		if transition.offset != current.offset || transition.daylight != current.daylight || transition.name != current.name {
+			kept = append(kept, transition)
+			current = transition
+		}
+	}
+	return first, kept
+}
+
+// build a real location out of the rules, so instants and recurrences across DST are right
+func (c *vtimezone) location(tzid string) (*time.Location, error) {
+	first, transitions := c.transitions()
+	loc, err := loadCustomZone(tzid, tzifData(first, transitions))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid VTIMEZONE %q", tzid)
+	}
+	return loc, nil
+}
+
+// TZif version 2 data, see RFC 8536, the version 1 part is left empty as Go only reads
+// the 64 bits part, first is kept as the unused type 0 so it is used before any transition
+func tzifData(first zoneTransition, transitions []zoneTransition) []byte {
+	type zoneType struct {
+		offset   int
+		daylight bool
+		name     string
+	}
+	types := []zoneType{{first.offset, first.daylight, first.name}}
+	typeIndex := make(map[zoneType]int)
+	indexes := make([]byte, len(transitions))
+	for i, transition := range transitions {
+		t := zoneType{transition.offset, transition.daylight, transition.name}
+		idx, ok := typeIndex[t]
+		if !ok {
+			idx = len(types)
+			typeIndex[t] = idx
+			types = append(types, t)
+		}
+		indexes[i] = byte(idx)
+	}
+	var names []byte
+	nameIndex := make(map[string]int)
+	for i := range types {
+		if types[i].name == "" {
+			types[i].name = formatUTCOffset(types[i].offset)
+		}
+		if _, ok := nameIndex[types[i].name]; !ok {
+			nameIndex[types[i].name] = len(names)
+			names = append(append(names, types[i].name...), 0)
+		}
+	}
+
+	var b bytes.Buffer
+	header := func(times, zones, chars int) {
+		b.WriteString("TZif2")
+		b.Write(make([]byte, 15))
+		for _, n := range []int{0, 0, 0, times, zones, chars} {
+			binary.Write(&b, binary.BigEndian, uint32(n))
+		}
+	}
+	header(0, 0, 0)
+	header(len(transitions), len(types), len(names))
+	for _, transition := range transitions {
+		binary.Write(&b, binary.BigEndian, transition.at)
+	}
+	b.Write(indexes)
+	for _, t := range types {
+		binary.Write(&b, binary.BigEndian, int32(t.offset))
+		daylight := byte(0)
+		if t.daylight {
+			daylight = 1
+		}
+		b.WriteByte(daylight)
+		b.WriteByte(byte(nameIndex[t.name]))
+	}
+	b.Write(names)
+	return b.Bytes()
+}
+
+// zone of t in loc
+func zoneAt(loc *time.Location, t int64) zoneTransition {
+	local := time.Unix(t, 0).In(loc)
+	name, offset := local.Zone()
+	return zoneTransition{at: t, offset: offset, daylight: local.IsDST(), name: name}
+}
+
+// transitions of loc between from and to by probing it every 12 hours
+func locationTransitions(loc *time.Location, from, to int64) (first zoneTransition, ret []zoneTransition) {
+	first = zoneAt(loc, from)
+	current := first
+	const step = 12 * 3600
+	for t := from + step; t < to+step; t += step {
+		next := zoneAt(loc, t)
+		if next.offset == current.offset && next.daylight == current.daylight && next.name == current.name {
+			continue
+		}
+		// the first second of the new zone is within (t - step, t]
+		low, high := t-step, t
+		for high-low > 1 {
+			mid := low + (high-low)/2
+			if zoneAt(loc, mid) == (zoneTransition{mid, current.offset, current.daylight, current.name}) {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		transition := zoneAt(loc, high)
+		ret = append(ret, transition)
+		current = transition
+	}
+	return
+}
+
+// VTIMEZONE describing loc from from to to, onsets with same offsets and name are
+// grouped into one observance with RDATE
+func newVTimezone(loc *time.Location, from, to int64) *vtimezone {
+	first, transitions := locationTransitions(loc, from, to)
+	zone := &vtimezone{}
+	// the zone in effect at from, as an onset not changing the offset
+	zone.observances = append(zone.observances, vtimezoneObservance{
+		daylight:   first.daylight,
+		name:       first.name,
+		start:      time.Unix(from+int64(first.offset), 0).UTC(),
+		offsetFrom: first.offset,
+		offsetTo:   first.offset,
+	})
+	previous := first
+	for _, transition := range transitions {
+		start := time.Unix(transition.at+int64(previous.offset), 0).UTC()
+		found := false
+		for i := range zone.observances[1:] {
+			observance := &zone.observances[i+1]
+			if observance.offsetFrom == previous.offset && observance.offsetTo == transition.offset &&
+				observance.daylight == transition.daylight && observance.name == transition.name {
+				observance.rdates = append(observance.rdates, start)
+				found = true
+				break
+			}
+		}
+		if !found {
+			zone.observances = append(zone.observances, vtimezoneObservance{
+				daylight:   transition.daylight,
+				name:       transition.name,
+				start:      start,
+				offsetFrom: previous.offset,
+				offsetTo:   transition.offset,
+			})
+		}
+		previous = transition
+	}
+	return zone
+}
@@ -0,0 +1,136 @@
+package calendar
+
+import (
+	"time"
+)
+
+// zone used to print floating events, they have no zone at all
+var floatingZone = time.FixedZone("floating", 0)
+
+// create the event from two instants, any zone can be used
+func NewCalendarEvent(id int, start, end time.Time) CalendarEvent {
+	return CalendarEvent{id, start.Unix(), end.Unix()}
+}
+
+// nil loc is UTC, same as Event.Zone
+func (c *CalendarEvent) StartTime(loc *time.Location) time.Time {
+	return time.Unix(c.Start, 0).In(orUTC(loc))
+}
+
+func (c *CalendarEvent) EndTime(loc *time.Location) time.Time {
+	return time.Unix(c.End, 0).In(orUTC(loc))
+}
+
+func orUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (c *CalendarEvent) Duration() time.Duration {
+	return time.Duration(c.End-c.Start) * time.Second
+}
+
+// move the event by years, months and days keeping the wall clock of start in loc
+// so a 9:00 meeting is still at 9:00 after a DST transition, the duration stays the same
+func (c *CalendarEvent) AddDateIn(years, months, days int, loc *time.Location) CalendarEvent {
+	start := c.StartTime(loc)
+	wall := time.Date(start.Year()+years, start.Month()+time.Month(months), start.Day()+days,
+		start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+	newStart := WallClockIn(wall, loc).Unix()
+	return CalendarEvent{c.Id, newStart, newStart + c.End - c.Start}
+}
+
+// resolve the wall clock of wall (its own zone is ignored) into an instant in loc
+// following RFC 5545: a time repeated by a DST transition is the first one,
+// a time skipped by a DST transition uses the offset before the gap, e.g. 2:30 becomes 3:30
+func WallClockIn(wall time.Time, loc *time.Location) time.Time {
+	loc = orUTC(loc)
+	asUTC := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(),
+		wall.Nanosecond(), time.UTC)
+	// transitions are never closer than a day, so the offsets around it cover every case
+	_, before := asUTC.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := asUTC.Add(24 * time.Hour).In(loc).Zone()
+
+	first := asUTC.Add(-time.Duration(before) * time.Second).In(loc)
+	second := asUTC.Add(-time.Duration(after) * time.Second).In(loc)
+	firstOk := sameWallClock(first, asUTC)
+	secondOk := sameWallClock(second, asUTC)
+	if firstOk && secondOk {
+		if second.Before(first) {
+			return second
+		}
+		return first
+	} else if secondOk {
+		return second
+	}
+	// either valid or skipped, both use the offset before
+	return first
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	return t.Year() == wall.Year() && t.YearDay() == wall.YearDay() &&
+		t.Hour() == wall.Hour() && t.Minute() == wall.Minute() && t.Second() == wall.Second()
+}
+
+// event keeping the zone of start, so it is rendered in the zone it was created in
+func NewZonedEvent(id int, start, end time.Time) Event {
+	return Event{CalendarEvent: NewCalendarEvent(id, start, end), Zone: start.Location()}
+}
+
+// floating event happens at the wall clock of start and end wherever the user is
+// e.g. 9:00 in New York for users in New York, 9:00 in Tokyo for users in Tokyo
+func NewFloatingEvent(id int, start, end time.Time) Event {
+	return Event{
+		CalendarEvent: CalendarEvent{id, floatingSeconds(start), floatingSeconds(end)},
+		Floating:      true,
+	}
+}
+
+// floating events keep the wall clock as if it was in UTC
+func floatingSeconds(wall time.Time) int64 {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(),
+		0, time.UTC).Unix()
+}
+
+// zone the event should be rendered in, UTC if not set
+func (c *Event) zone() *time.Location {
+	if c.Floating || c.AllDay {
+		return floatingZone
+	} else if c.Zone == nil {
+		return time.UTC
+	}
+	return c.Zone
+}
+
+// the event in real instants for a user in loc, only floating and all-day events depend on loc
+func (c *Event) Instants(loc *time.Location) CalendarEvent {
+	if c.AllDay {
+		// the day ends right before next midnight in loc, which may not be 24 hours later
+		first, last := c.Dates()
+		return CalendarEvent{c.Id, first.In(loc).Unix(), last.AddDays(1).In(loc).Unix() - 1}
+	} else if !c.Floating {
+		return c.CalendarEvent
+	}
+	return CalendarEvent{
+		c.Id,
+		WallClockIn(time.Unix(c.Start, 0).UTC(), loc).Unix(),
+		WallClockIn(time.Unix(c.End, 0).UTC(), loc).Unix(),
+	}
+}
+
+// start and end of the event as seen by a user in loc
+func (c *Event) TimesIn(loc *time.Location) (start, end time.Time) {
+	instants := c.Instants(loc)
+	return instants.StartTime(loc), instants.EndTime(loc)
+}
+
+// the events in real instants for a user in loc, ready for FindOverlapPairs*
+func (c Events) Instants(loc *time.Location) CalendarEvents {
+	ret := make(CalendarEvents, len(c))
+	for i := range c {
+		ret[i] = c[i].Instants(loc)
+	}
+	return ret
+}
@@ -0,0 +1,104 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("zone %s is not available: %s", name, err)
+	}
+	return loc
+}
+
+func TestWallClockIn(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	cases := []struct {
+		wall     time.Time
+		expected string
+	}{
+		// normal time
+		{time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), "2026-10-18T09:00:00-04:00"},
+		// skipped by spring forward, uses the offset before the gap
+		{time.Date(2026, 3, 8, 2, 30, 0, 0, time.UTC), "2026-03-08T03:30:00-04:00"},
+		// repeated by fall back, uses the first one
+		{time.Date(2026, 11, 1, 1, 30, 0, 0, time.UTC), "2026-11-01T01:30:00-04:00"},
+		{time.Date(2026, 11, 1, 2, 30, 0, 0, time.UTC), "2026-11-01T02:30:00-05:00"},
+	}
+	for _, c := range cases {
+		if ret := WallClockIn(c.wall, newYork).Format(time.RFC3339); ret != c.expected {
+			t.Logf("%s should be %s instead of %s", c.wall, c.expected, ret)
+			t.FailNow()
+		}
+	}
+}
+
+func TestCalendarEvent_AddDateIn(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	evt := NewCalendarEvent(0, time.Date(2026, 10, 31, 9, 0, 0, 0, newYork), time.Date(2026, 10, 31, 10, 0, 0, 0, newYork))
+	// across the fall back transition, still 9:00 in New York but 25 hours later
+	moved := evt.AddDateIn(0, 0, 1, newYork)
+	if moved.StartTime(newYork).Hour() != 9 || moved.Start-evt.Start != 25*3600 {
+		t.Logf("moved event should start at 9:00 instead of %s", moved.StartTime(newYork))
+		t.FailNow()
+	}
+	if moved.Duration() != time.Hour {
+		t.Logf("duration should be kept instead of %s", moved.Duration())
+		t.FailNow()
+	}
+}
+
+func TestEvent_Instants(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	tokyo := loadLocation(t, "Asia/Tokyo")
+
+	floating := NewFloatingEvent(0, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC))
+	// 9:00 - 10:00 in New York is 22:00 - 23:00 in Tokyo
+	zoned := NewZonedEvent(1, time.Date(2026, 10, 18, 22, 30, 0, 0, tokyo), time.Date(2026, 10, 18, 23, 0, 0, 0, tokyo))
+	events := Events{floating, zoned}
+
+	if ret := FindOverlapPairsSweep(events.Instants(newYork)); len(ret) != 1 {
+		t.Logf("events should overlap for users in New York instead of %v", ret)
+		t.FailNow()
+	}
+	if ret := FindOverlapPairsSweep(events.Instants(tokyo)); len(ret) != 0 {
+		t.Logf("events should not overlap for users in Tokyo instead of %v", ret)
+		t.FailNow()
+	}
+
+	start, _ := floating.TimesIn(tokyo)
+	if start.Format(time.RFC3339) != "2026-10-18T09:00:00+09:00" {
+		t.Logf("floating event should start at 9:00 in Tokyo instead of %s", start)
+		t.FailNow()
+	}
+	expected := "Untitled event (#1) 2026-10-18 22:30 JST -> 23:00 JST"
+	if ret := zoned.ToString(); ret != expected {
+		t.Logf("summary should be %q instead of %q", expected, ret)
+		t.FailNow()
+	}
+	expected = "Untitled event (#0) 2026-10-18 09:00 floating -> 10:00 floating"
+	if ret := floating.ToString(); ret != expected {
+		t.Logf("summary should be %q instead of %q", expected, ret)
+		t.FailNow()
+	}
+}
+
+// nil is UTC like Event.Zone, instead of panicking in Time.In
+func TestNilLocation(t *testing.T) {
+	evt := NewZonedEvent(0, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC))
+	if start := evt.StartTime(nil); start.Location() != time.UTC || start.Hour() != 9 || evt.EndTime(nil).Hour() != 10 {
+		t.Logf("expect 9:00 in UTC but got %s", start)
+		t.FailNow()
+	}
+	if moved := evt.AddDateIn(0, 0, 1, nil); moved.Start-evt.Start != 24*3600 {
+		t.Logf("expect a day later but got %d", moved.Start)
+		t.FailNow()
+	}
+	floating := NewFloatingEvent(1, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC))
+	if start, end := floating.TimesIn(nil); start.Hour() != 9 || end.Hour() != 10 {
+		t.Logf("expect 9:00 to 10:00 but got %s and %s", start, end)
+		t.FailNow()
+	}
+}